@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runCommand runs one of the Mr Mo maintenance commands
+func runCommand(ctx context.Context, command string, args []string) {
+	switch command {
+	case "bootstrap":
+		runBootstrap(ctx, args)
+	case "status":
+		runStatus()
+	case "restore":
+		runRestore(ctx, args)
+	case "layout":
+		runLayout(ctx, args)
+	default:
+		log.Fatalf("Unknown command '%s'. Available commands: bootstrap, status, restore, layout", command)
+	}
+}
+
+// runBootstrap pairs existing entities across the source and target orgs, shows the proposed pairs for review and
+// writes the accepted pairs into the mapping table.
+//
+// Usage: bootstrap [-key name] [-yes] <resource type> [<resource type>...]
+func runBootstrap(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	matchKey := flags.String("key", mrmo.BootstrapNameKey, "pair entities by name, or by the value of this attribute path")
+	autoApprove := flags.Bool("yes", false, "write the proposed pairs without asking for confirmation")
+	_ = flags.Parse(args)
+
+	resourceTypes := flags.Args()
+	if len(resourceTypes) == 0 {
+		log.Fatal("bootstrap requires at least one resource type")
+	}
+
+	pairs, diags := mrmo.Bootstrap(ctx, credsFilePath, resourceTypes, *matchKey)
+	printDiagnosticWarnings(diags)
+	if diags.HasError() {
+		log.Fatal(diags)
+	}
+
+	if len(pairs) == 0 {
+		log.Println("No new pairs found")
+		return
+	}
+
+	fmt.Printf("Proposed pairs (matched by %s):\n", *matchKey)
+	for _, p := range pairs {
+		fmt.Printf("  %s %q: %s -> %s (%s)\n", p.ResourceType, p.Key, p.SourceEntityId, p.TargetEntityId, p.TargetOrgName)
+	}
+
+	if !*autoApprove && !confirm(fmt.Sprintf("Write %d mappings to the mapping table?", len(pairs))) {
+		log.Println("Bootstrap cancelled")
+		return
+	}
+
+	if err := mrmo.ApplyBootstrapPairs(pairs); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runStatus prints the source and target orgs, along with the mappings and overrides known for each target
+func runStatus() {
+	status, err := mrmo.GetStatus(credsFilePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Source: %s (%s) %s\n", status.Source.Name, status.Source.OrgId, status.Source.Region)
+	for _, target := range status.Targets {
+		fmt.Printf("Target: %s (%s) %s\n", target.Name, target.OrgId, target.Region)
+		fmt.Printf("  Mapped entities: %d\n", target.MappedEntities)
+		if target.OverridesFile == "" {
+			fmt.Println("  Overrides: none")
+			continue
+		}
+
+		fmt.Printf("  Overrides: %s\n", target.OverridesFile)
+		resourceTypes := make([]string, 0, len(target.Overrides))
+		for resourceType := range target.Overrides {
+			resourceTypes = append(resourceTypes, resourceType)
+		}
+		sort.Strings(resourceTypes)
+		for _, resourceType := range resourceTypes {
+			fmt.Printf("    %s: %d\n", resourceType, target.Overrides[resourceType])
+		}
+	}
+}
+
+// runRestore rolls a target org back to one of its snapshots. Without a snapshot ID, the org's snapshots are listed.
+//
+// Usage: restore -org <org ID> [-yes] [<snapshot ID>]
+func runRestore(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	orgId := flags.String("org", "", "the ID of the target org to restore")
+	autoApprove := flags.Bool("yes", false, "restore without asking for confirmation")
+	_ = flags.Parse(args)
+
+	if *orgId == "" {
+		log.Fatal("restore requires -org")
+	}
+
+	if flags.NArg() == 0 {
+		snapshots, err := mrmo.ListSnapshots(ctx, credsFilePath, *orgId)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(snapshots) == 0 {
+			log.Printf("No snapshots found for org '%s'", *orgId)
+			return
+		}
+		for _, s := range snapshots {
+			operation := "apply"
+			if s.IsDelete {
+				operation = "delete"
+			}
+			fmt.Printf("  %s: before %s of %s '%s' (%d files, %d mappings)\n", s.Id, operation, s.ResourceType, s.EntityId, len(s.Files), len(s.Mappings))
+		}
+		return
+	}
+
+	snapshotId := flags.Arg(0)
+	if !*autoApprove && !confirm(fmt.Sprintf("Restore the configs, state and mappings of org '%s' to snapshot '%s'?", *orgId, snapshotId)) {
+		log.Println("Restore cancelled")
+		return
+	}
+
+	diags := mrmo.RestoreSnapshot(ctx, credsFilePath, *orgId, snapshotId)
+	printDiagnosticWarnings(diags)
+	if diags.HasError() {
+		log.Fatal(diags)
+	}
+}
+
+// runLayout migrates the config directory of a target org to the layout set in the credentials file
+//
+// Usage: layout -org <org ID> [-yes]
+func runLayout(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("layout", flag.ExitOnError)
+	orgId := flags.String("org", "", "the ID of the target org to migrate")
+	autoApprove := flags.Bool("yes", false, "migrate without asking for confirmation")
+	_ = flags.Parse(args)
+
+	if *orgId == "" {
+		log.Fatal("layout requires -org")
+	}
+
+	if !*autoApprove && !confirm(fmt.Sprintf("Migrate the config directory of org '%s' to the configured layout?", *orgId)) {
+		log.Println("Migration cancelled")
+		return
+	}
+
+	diags := mrmo.MigrateLayout(ctx, credsFilePath, *orgId)
+	printDiagnosticWarnings(diags)
+	if diags.HasError() {
+		log.Fatal(diags)
+	}
+}
+
+// confirm asks the user a yes/no question on stdin
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/charliecon/mr-mo-trial-run/mrmo"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 const (
@@ -15,10 +18,16 @@ const (
 )
 
 func main() {
-	var (
-		ctx   = context.Background()
-		diags diag.Diagnostics
-	)
+	var diags diag.Diagnostics
+
+	// Cancel on shutdown, so that running tofu commands are interrupted and release their state locks
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 {
+		runCommand(ctx, os.Args[1], os.Args[2:])
+		return
+	}
 
 	defer func() {
 		printDiagnosticWarnings(diags)
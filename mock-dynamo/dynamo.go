@@ -6,12 +6,13 @@ import (
 	"os"
 )
 
-const tableFilePath = "./mock-dynamo/table.json"
+// TableFilePath is the path of the mapping table, relative to the working directory
+var TableFilePath = "./mock-dynamo/table.json"
 
 func GetTargetIdBySourceId(sourceId, orgId string) (string, error) {
 	item, err := GetItem(sourceId)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	for _, target := range item.TargetInfo {
@@ -43,6 +44,111 @@ func GetItem(sourceEntityId string) (*Item, error) {
 	return nil, fmt.Errorf("failed to find item for source ID '%s'", sourceEntityId)
 }
 
+// GetSourceIdByTargetId returns the ID of the source entity mapped to the target entity in the given org, or an empty
+// string if the target entity is not mapped
+func GetSourceIdByTargetId(targetId, orgId string) (string, error) {
+	table, err := loadData()
+	if err != nil {
+		return "", err
+	}
+
+	for _, item := range table.Items {
+		for _, target := range item.TargetInfo {
+			if target.OrgId == orgId && target.TargetEntityId == targetId {
+				return item.SourceEntityId, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// GetSourceEntityIds returns the ID of every source entity tracked in the mapping table, reading the table once
+func GetSourceEntityIds() (map[string]bool, error) {
+	table, err := loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	for _, item := range table.Items {
+		ids[item.SourceEntityId] = true
+	}
+	return ids, nil
+}
+
+// CountTargetMappings returns the number of source entities that are mapped to an entity in the given org
+func CountTargetMappings(orgId string) (int, error) {
+	table, err := loadData()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, item := range table.Items {
+		for _, target := range item.TargetInfo {
+			if target.OrgId == orgId {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// GetTargetMappings returns every source entity mapped to an entity in the given org
+func GetTargetMappings(orgId string) ([]Mapping, error) {
+	table, err := loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := make([]Mapping, 0)
+	for _, item := range table.Items {
+		for _, target := range item.TargetInfo {
+			if target.OrgId == orgId {
+				mappings = append(mappings, Mapping{
+					ResourceType:   item.ResourceType,
+					SourceEntityId: item.SourceEntityId,
+					TargetEntityId: target.TargetEntityId,
+				})
+				break
+			}
+		}
+	}
+	return mappings, nil
+}
+
+// DeleteTargetInfo removes the mapping of the source entity to the given org. The item is deleted once the source
+// entity is no longer mapped to any org.
+func DeleteTargetInfo(sourceEntityId, orgId string) error {
+	table, err := loadData()
+	if err != nil {
+		return err
+	}
+
+	newItemsSlice := make([]Item, 0)
+	for _, item := range table.Items {
+		if item.SourceEntityId != sourceEntityId {
+			newItemsSlice = append(newItemsSlice, item)
+			continue
+		}
+
+		newTargetInfo := make([]TargetInfo, 0)
+		for _, target := range item.TargetInfo {
+			if target.OrgId != orgId {
+				newTargetInfo = append(newTargetInfo, target)
+			}
+		}
+		if len(newTargetInfo) > 0 {
+			item.TargetInfo = newTargetInfo
+			newItemsSlice = append(newItemsSlice, item)
+		}
+	}
+
+	table.Items = newItemsSlice
+	return writeData(*table, TableFilePath)
+}
+
 func DeleteItem(sourceEntityId string) error {
 	table, err := loadData()
 	if err != nil {
@@ -57,7 +163,7 @@ func DeleteItem(sourceEntityId string) error {
 	}
 
 	table.Items = newItemsSlice
-	return writeData(*table, tableFilePath)
+	return writeData(*table, TableFilePath)
 }
 
 // UpdateItem updates or creates an item mapping between a source entity and its target information.
@@ -100,7 +206,7 @@ func UpdateItem(resourceType, sourceEntityId, targetOrgId, targetEntityId string
 		for i := range targetItem.TargetInfo {
 			if targetItem.TargetInfo[i].OrgId == targetOrgId {
 				targetItem.TargetInfo[i].TargetEntityId = targetEntityId
-				return writeData(*table, tableFilePath)
+				return writeData(*table, TableFilePath)
 			}
 		}
 
@@ -123,11 +229,11 @@ func UpdateItem(resourceType, sourceEntityId, targetOrgId, targetEntityId string
 		})
 	}
 
-	return writeData(*table, tableFilePath)
+	return writeData(*table, TableFilePath)
 }
 
 func loadData() (*Table, error) {
-	data, err := os.ReadFile(tableFilePath)
+	data, err := os.ReadFile(TableFilePath)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,47 @@
+package mock_dynamo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnitGetTargetMappings(t *testing.T) {
+	UseTestTable(t,
+		Item{ResourceType: "genesyscloud_group", SourceEntityId: "source-1", TargetInfo: []TargetInfo{
+			{OrgId: "org-a", TargetEntityId: "target-1a"},
+			{OrgId: "org-b", TargetEntityId: "target-1b"},
+		}},
+		Item{ResourceType: "genesyscloud_user", SourceEntityId: "source-2", TargetInfo: []TargetInfo{
+			{OrgId: "org-b", TargetEntityId: "target-2b"},
+		}},
+	)
+
+	mappings, err := GetTargetMappings("org-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Mapping{{ResourceType: "genesyscloud_group", SourceEntityId: "source-1", TargetEntityId: "target-1a"}}
+	if !reflect.DeepEqual(mappings, expected) {
+		t.Errorf("expected mappings %v, got %v", expected, mappings)
+	}
+
+	if mappings, err = GetTargetMappings("org-c"); err != nil || mappings == nil || len(mappings) != 0 {
+		t.Errorf("expected an empty list of mappings for an org without mappings, got %v (%v)", mappings, err)
+	}
+}
+
+func TestUnitGetSourceEntityIds(t *testing.T) {
+	UseTestTable(t,
+		Item{ResourceType: "genesyscloud_group", SourceEntityId: "source-1", TargetInfo: []TargetInfo{{OrgId: "org-a", TargetEntityId: "target-1a"}}},
+		Item{ResourceType: "genesyscloud_user", SourceEntityId: "source-2"},
+	)
+
+	ids, err := GetSourceEntityIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"source-1": true, "source-2": true}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected source entities %v, got %v", expected, ids)
+	}
+}
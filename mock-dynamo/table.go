@@ -10,6 +10,13 @@ type Item struct {
 	TargetInfo     []TargetInfo `json:"targetInfo"`
 }
 
+// Mapping is the mapping of a single source entity to an entity in one target org
+type Mapping struct {
+	ResourceType   string `json:"resourceType"`
+	SourceEntityId string `json:"sourceEntityId"`
+	TargetEntityId string `json:"targetEntityId"`
+}
+
 type TargetInfo struct {
 	OrgId          string `json:"orgId"`
 	TargetEntityId string `json:"targetEntityId"`
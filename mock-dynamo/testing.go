@@ -0,0 +1,24 @@
+package mock_dynamo
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// UseTestTable points the mapping table at a temporary table holding items for the duration of the test, so that tests
+// of this and other packages never touch the table in the working directory
+func UseTestTable(t testing.TB, items ...Item) {
+	t.Helper()
+	previous := TableFilePath
+	TableFilePath = filepath.Join(t.TempDir(), "table.json")
+	t.Cleanup(func() {
+		TableFilePath = previous
+	})
+
+	if items == nil {
+		items = []Item{}
+	}
+	if err := writeData(Table{Items: items}, TableFilePath); err != nil {
+		t.Fatal(err)
+	}
+}
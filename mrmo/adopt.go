@@ -0,0 +1,151 @@
+package mrmo
+
+import (
+	"context"
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"log"
+	"path/filepath"
+	"sort"
+)
+
+// adoptExistingEntity prevents the first apply of a source entity to a target org from duplicating an entity that
+// already exists there, e.g. one created by hand or one whose mapping was lost. If the source entity has neither a
+// mapping nor a config file for the target org, the target org is searched for an entity of the same type with the
+// same name. If exactly one is found, and it is not already managed for another source entity, an import block is
+// added to the config so that tofu adopts it. The mapping is recorded once the apply succeeds.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - resourceConfig: The resolved resource config for the target org
+//   - fm: The FileManager of the target org
+//   - target: The target org data
+//
+// Returns:
+//   - util.JsonMap: The resource config, with an import block if a matching entity was found
+//   - diag.Diagnostics: An error if more than one entity in the target org matches, since adopting either one could be
+//     wrong, and creating another would add to the duplicates. An error is also returned if the matching entity is
+//     already mapped to another source entity, or tracked under another address in the config directory, since
+//     adopting it would have two resources manage the same entity.
+func (m *MrMo) adoptExistingEntity(ctx context.Context, resourceConfig util.JsonMap, fm *FileManager, target orgManager.OrgData) (util.JsonMap, diag.Diagnostics) {
+	if fm.exists {
+		return resourceConfig, nil
+	}
+	if targetId, err := mockDynamo.GetTargetIdBySourceId(m.Id, target.OrgId); err == nil && targetId != "" {
+		return resourceConfig, nil
+	}
+
+	sourceEntities, err := m.getEntities(ctx, m.ResourceType, m.OrgManager.Source)
+	if err != nil {
+		return resourceConfig, diag.FromErr(err)
+	}
+	sourceEntity, ok := sourceEntities[m.Id]
+	if !ok || sourceEntity == nil {
+		return resourceConfig, diag.Errorf("%s '%s' not found in source org", m.ResourceType, m.Id)
+	}
+	name, err := m.getEntityName(ctx, m.ResourceType, m.Id, m.OrgManager.Source)
+	if err != nil {
+		return resourceConfig, diag.FromErr(err)
+	}
+	if name == "" {
+		return resourceConfig, nil
+	}
+
+	matches, err := m.findEntityIdsByName(ctx, m.ResourceType, target, name, sourceEntity.BlockLabel)
+	if err != nil {
+		return resourceConfig, diag.FromErr(err)
+	}
+	switch len(matches) {
+	case 0:
+		return resourceConfig, nil
+	case 1:
+	default:
+		return resourceConfig, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Found %d %s entities named '%s' in org '%s'", len(matches), m.ResourceType, name, target.OrgId),
+			Detail:   fmt.Sprintf("Cannot decide which of %v to adopt for source entity '%s'. Add a mapping for one of them.", matches, m.Id),
+		}}
+	}
+
+	if err = ensureTargetNotMappedElsewhere(m.ResourceType, matches[0], target.OrgId, m.Id); err != nil {
+		return resourceConfig, adoptConflictDiagnostic(m.ResourceType, matches[0], m.Id, err)
+	}
+	address, err := m.findManagedAddress(ctx, fm, matches[0])
+	if err != nil {
+		return resourceConfig, diag.FromErr(err)
+	}
+	if address != "" && address != m.ResourcePath {
+		err = fmt.Errorf("%s '%s' in org '%s' is already managed as '%s'", m.ResourceType, matches[0], target.OrgId, address)
+		return resourceConfig, adoptConflictDiagnostic(m.ResourceType, matches[0], m.Id, err)
+	}
+
+	log.Printf("Adopting existing %s '%s' in org '%s' for source entity '%s'", m.ResourceType, matches[0], target.OrgId, m.Id)
+	return addImportBlock(resourceConfig, m.ResourcePath, matches[0]), nil
+}
+
+// adoptConflictDiagnostic returns the error diagnostic for a target entity that cannot be adopted because another
+// resource already manages it
+func adoptConflictDiagnostic(resourceType, targetEntityId, sourceEntityId string, err error) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("Cannot adopt %s '%s' for source entity '%s'", resourceType, targetEntityId, sourceEntityId),
+		Detail:   fmt.Sprintf("%v. Rename one of the entities, or fix the mapping table.", err),
+	}}
+}
+
+// findManagedAddress returns the address under which the target entity is already managed in the config directory of
+// fm, either in its state or by an import block of one of its config files, or an empty string if it is not managed
+func (m *MrMo) findManagedAddress(ctx context.Context, fm *FileManager, targetEntityId string) (string, error) {
+	address, err := findStateAddress(ctx, m.Executor, fm.targetConfigDir, m.ResourceType, targetEntityId)
+	if err != nil || address != "" {
+		return address, err
+	}
+	return findImportAddress(fm.targetConfigDir, targetEntityId)
+}
+
+// findImportAddress returns the address of the first import block of the *.tf.json files in dir that imports the
+// entity with the given ID, or an empty string if there is none
+func findImportAddress(dir, id string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf.json"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		config, err := readConfigFile(file)
+		if err != nil {
+			return "", err
+		}
+		importBlocks, _ := config["import"].([]any)
+		for _, importBlock := range importBlocks {
+			block, ok := importBlock.(map[string]any)
+			if !ok || block["id"] != id {
+				continue
+			}
+			if to, ok := block["to"].(string); ok {
+				return to, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// addImportBlock returns a copy of resourceConfig with an import block adopting the entity with the given ID at address
+func addImportBlock(resourceConfig util.JsonMap, address, id string) util.JsonMap {
+	withImport := make(util.JsonMap, len(resourceConfig)+1)
+	for k, v := range resourceConfig {
+		withImport[k] = v
+	}
+
+	importBlock := map[string]any{"to": address, "id": id}
+	if existing, ok := withImport["import"].([]any); ok {
+		withImport["import"] = append(append([]any{}, existing...), importBlock)
+	} else {
+		withImport["import"] = []any{importBlock}
+	}
+	return withImport
+}
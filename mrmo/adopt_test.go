@@ -0,0 +1,110 @@
+package mrmo
+
+import (
+	"context"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"reflect"
+	"testing"
+)
+
+func TestUnitAddImportBlock(t *testing.T) {
+	resourceConfig := util.JsonMap{
+		"resource": map[string]any{"genesyscloud_group": map[string]any{"example": map[string]any{"name": "example"}}},
+	}
+
+	withImport := addImportBlock(resourceConfig, "genesyscloud_group.example", "target-id")
+
+	expected := []any{map[string]any{"to": "genesyscloud_group.example", "id": "target-id"}}
+	if !reflect.DeepEqual(withImport["import"], expected) {
+		t.Errorf("expected import block %v, got %v", expected, withImport["import"])
+	}
+	if _, ok := resourceConfig["import"]; ok {
+		t.Error("expected the original resource config to be left unchanged")
+	}
+	if !reflect.DeepEqual(withImport["resource"], resourceConfig["resource"]) {
+		t.Error("expected the resource blocks to be kept")
+	}
+}
+
+// withAdoptTestEntities makes m adopt for source-1, named "Foo Bar" in the source org, with the given entities of org-a
+// cached by ID and name
+func withAdoptTestEntities(m *MrMo, targetNames map[string]string) *MrMo {
+	m.ResourcePath = testResourceType + ".Foo_Bar"
+	m.OrgManager.Source = orgManager.OrgData{OrgId: "source-org"}
+	withCachedEntities(m, "source-org", testResourceType, map[string]string{"source-1": "Foo Bar"})
+	return withCachedEntities(m, testOrgId, testResourceType, targetNames)
+}
+
+func TestUnitAdoptExistingEntity(t *testing.T) {
+	mockDynamo.UseTestTable(t)
+	m := withAdoptTestEntities(newTestMrMo(t), map[string]string{"target-1": "Foo_Bar", "target-2": "Foo Bar"})
+	fm := m.newFileManager(testOrgId)
+
+	withImport, diags := m.adoptExistingEntity(context.Background(), util.JsonMap{}, fm, orgManager.OrgData{OrgId: testOrgId})
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	expected := []any{map[string]any{"to": m.ResourcePath, "id": "target-2"}}
+	if !reflect.DeepEqual(withImport["import"], expected) {
+		t.Errorf("expected the entity with the same name to be adopted %v, got %v", expected, withImport["import"])
+	}
+}
+
+func TestUnitAdoptExistingEntityAmbiguousName(t *testing.T) {
+	mockDynamo.UseTestTable(t)
+	m := withAdoptTestEntities(newTestMrMo(t), map[string]string{"target-1": "Foo Bar", "target-2": "Foo Bar"})
+	fm := m.newFileManager(testOrgId)
+
+	withImport, diags := m.adoptExistingEntity(context.Background(), util.JsonMap{}, fm, orgManager.OrgData{OrgId: testOrgId})
+	if !diags.HasError() {
+		t.Fatal("expected an error for two target entities with the same name")
+	}
+	if _, ok := withImport["import"]; ok {
+		t.Error("expected no import block for an ambiguous name")
+	}
+}
+
+func TestUnitAdoptExistingEntityAlreadyManaged(t *testing.T) {
+	targetOrg := orgManager.OrgData{OrgId: testOrgId}
+
+	t.Run("mapped to another source entity", func(t *testing.T) {
+		mockDynamo.UseTestTable(t, mappingItem(testResourceType, "source-2", testOrgId, "target-1"))
+		m := withAdoptTestEntities(newTestMrMo(t), map[string]string{"target-1": "Foo Bar"})
+
+		_, diags := m.adoptExistingEntity(context.Background(), util.JsonMap{}, m.newFileManager(testOrgId), targetOrg)
+		if !diags.HasError() {
+			t.Error("expected an error for a target entity mapped to another source entity")
+		}
+	})
+
+	t.Run("in state under another address", func(t *testing.T) {
+		mockDynamo.UseTestTable(t)
+		fake := &fakeExecutor{state: []executor.StateResource{{
+			Address: testResourceType + ".other",
+			Type:    testResourceType,
+			Values:  map[string]any{"id": "target-1"},
+		}}}
+		m := withAdoptTestEntities(newTestMrMo(t), map[string]string{"target-1": "Foo Bar"})
+		m.Executor = fake
+
+		_, diags := m.adoptExistingEntity(context.Background(), util.JsonMap{}, m.newFileManager(testOrgId), targetOrg)
+		if !diags.HasError() {
+			t.Error("expected an error for a target entity already in state")
+		}
+	})
+
+	t.Run("imported under another address", func(t *testing.T) {
+		mockDynamo.UseTestTable(t)
+		m := withAdoptTestEntities(newTestMrMo(t), map[string]string{"target-1": "Foo Bar"})
+		fm := m.newFileManager(testOrgId)
+		writeTestFile(t, fm.targetConfigDir, "source-2.tf.json", `{"import":[{"to":"`+testResourceType+`.other","id":"target-1"}]}`)
+
+		_, diags := m.adoptExistingEntity(context.Background(), util.JsonMap{}, fm, targetOrg)
+		if !diags.HasError() {
+			t.Error("expected an error for a target entity imported by another config file")
+		}
+	})
+}
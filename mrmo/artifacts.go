@@ -0,0 +1,114 @@
+package mrmo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/tfexporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// artifactAttributes maps resource types to the attribute paths that reference files written alongside the exported
+// config. The paths are relative to the export directory and must be relative to the target config directory after
+// replication.
+var artifactAttributes = map[string][]string{
+	flowResourceType:                          {"filepath"},
+	"genesyscloud_script":                     {"filepath"},
+	"genesyscloud_architect_user_prompt":      {"resources.filename"},
+	"genesyscloud_outbound_contact_list":      {"contacts_filepath"},
+	"genesyscloud_architect_grammar_language": {"voice_file_data.file_name", "dtmf_file_data.file_name"},
+}
+
+// artifact is a file referenced by an exported resource config
+type artifact struct {
+	RelativePath string `json:"relativePath"`
+	Checksum     string `json:"checksum"`
+
+	content []byte
+}
+
+// collectArtifacts reads every file referenced by the exported resource config from the export directory.
+//
+// Parameters:
+//   - resourceConfig: The exported config for the resource
+//
+// Returns:
+//   - []artifact: The referenced files, with their content and sha256 checksum
+//   - error: Error if a referenced file cannot be read
+func (m *MrMo) collectArtifacts(resourceConfig util.JsonMap) (artifacts []artifact, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("collectArtifacts: %w", err)
+		}
+	}()
+
+	attributePaths := artifactAttributes[m.ResourceType]
+	if len(attributePaths) == 0 {
+		return nil, nil
+	}
+
+	resourceBlock, ok := resourceConfig["resource"].(map[string]tfexporter.ResourceJSONMaps)
+	if !ok {
+		return nil, fmt.Errorf("failed to read resource block from config for '%s'", m.ResourcePath)
+	}
+	individualResourceConfig := resourceBlock[m.ResourceType][m.ResourceLabel]
+
+	for _, path := range attributePaths {
+		for _, value := range getValuesAtPath(individualResourceConfig, path) {
+			relativePath, ok := value.(string)
+			if !ok || relativePath == "" {
+				continue
+			}
+
+			sourcePath := relativePath
+			if !filepath.IsAbs(sourcePath) {
+				sourcePath = filepath.Join(exportDirectory, relativePath)
+			}
+
+			content, err := os.ReadFile(sourcePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file '%s' referenced by '%s.%s': %w", sourcePath, m.ResourcePath, path, err)
+			}
+
+			log.Printf("Collected file '%s' referenced by '%s.%s'", relativePath, m.ResourcePath, path)
+			artifacts = append(artifacts, artifact{
+				RelativePath: relativePath,
+				Checksum:     checksum(content),
+				content:      content,
+			})
+		}
+	}
+	return artifacts, nil
+}
+
+// prepareArtifactsForTarget returns the artifacts as they should be written for the target org. Architect flow YAML
+// has its GUIDs resolved to the target org, and its checksum recomputed; all other artifacts are copied as-is. A
+// diagnostic is returned for every GUID of a flow YAML that cannot be resolved (see resolveGuidsInFlowFile).
+func (m *MrMo) prepareArtifactsForTarget(target orgManager.OrgData) (_ []artifact, diags diag.Diagnostics) {
+	if m.ResourceType != flowResourceType {
+		return m.Artifacts, nil
+	}
+
+	targetArtifacts := make([]artifact, 0, len(m.Artifacts))
+	for _, a := range m.Artifacts {
+		content, resolveDiags := m.resolveGuidsInFlowFile(a.content, a.RelativePath, target)
+		diags = append(diags, resolveDiags...)
+		targetArtifacts = append(targetArtifacts, artifact{
+			RelativePath: a.RelativePath,
+			Checksum:     checksum(content),
+			content:      content,
+		})
+	}
+	return targetArtifacts, diags
+}
+
+// checksum returns the hex encoded sha256 checksum of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,485 @@
+package mrmo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	snapshotMetadataFile = "snapshot.json"
+	snapshotStateFile    = "state.json"
+	snapshotConfigDir    = "config"
+
+	// snapshotIdTimeFormat keeps the snapshots of a retried message apart, and sorts snapshot IDs by time
+	snapshotIdTimeFormat = "20060102T150405.000000000Z"
+
+	defaultRetainedSnapshots = 100
+)
+
+// Snapshot describes a backup of a target org's config directory, state and mappings, taken before a message was
+// applied to it
+type Snapshot struct {
+	Id           string    `json:"id"`
+	OrgId        string    `json:"orgId"`
+	MessageId    string    `json:"messageId"`
+	ResourceType string    `json:"resourceType"`
+	EntityId     string    `json:"entityId"`
+	IsDelete     bool      `json:"isDelete"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// Executor is the name of the executor the state was pulled with. State can only be restored with the same one.
+	Executor string `json:"executor"`
+	// Files are the paths, relative to the config directory and separated by slashes, of its *.tf.json files, layout
+	// manifest, artifact manifests and artifacts
+	Files []string `json:"files"`
+	// Checksums are the checksums of Files, by path
+	Checksums map[string]string `json:"checksums,omitempty"`
+	HasState  bool              `json:"hasState"`
+	// StateSerial and StateLineage identify the version of the state. Together with Checksums and Mappings, they tell
+	// whether anything changed since the snapshot.
+	StateSerial  int64                `json:"stateSerial,omitempty"`
+	StateLineage string               `json:"stateLineage,omitempty"`
+	Mappings     []mockDynamo.Mapping `json:"mappings"`
+}
+
+// backupTarget snapshots the *.tf.json files, layout manifest, artifacts and state of the target's config directory,
+// along with the target's entries in the mapping table, into the target's backups in the config store. The snapshot is
+// keyed by a timestamp and the message ID. Once it is written, the oldest snapshots of the target beyond the retention
+// limit are deleted.
+//
+// If neither the state serial, the files nor the mappings changed since the latest snapshot of the target, nothing is
+// written and the latest snapshot is returned. The state still has to be pulled to read its serial.
+func (m *MrMo) backupTarget(ctx context.Context, fm *FileManager, target orgManager.OrgData) (_ *Snapshot, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to back up org '%s': %w", target.OrgId, err)
+		}
+	}()
+
+	iac, err := m.getExecutor()
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now().UTC()
+	snapshot := &Snapshot{
+		Id:           fmt.Sprintf("%s-%s", createdAt.Format(snapshotIdTimeFormat), sanitizeString(m.MessageId)),
+		OrgId:        target.OrgId,
+		MessageId:    m.MessageId,
+		ResourceType: m.ResourceType,
+		EntityId:     m.Id,
+		IsDelete:     m.IsDelete,
+		CreatedAt:    createdAt,
+		Executor:     iac.Name(),
+	}
+	snapshotPrefix := path.Join(fm.backupsPrefix, snapshot.Id)
+
+	if snapshot.Files, err = snapshotConfigFiles(fm.targetConfigDir); err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(snapshot.Files))
+	snapshot.Checksums = make(map[string]string, len(snapshot.Files))
+	for _, file := range snapshot.Files {
+		if files[file], err = os.ReadFile(filepath.Join(fm.targetConfigDir, filepath.FromSlash(file))); err != nil {
+			return nil, err
+		}
+		snapshot.Checksums[file] = checksum(files[file])
+	}
+
+	// the state can only be read once the directory is initialized, e.g. with its backend
+	if diags := iac.Init(ctx, fm.targetConfigDir); diags.HasError() {
+		return nil, fmt.Errorf("%v", diags)
+	}
+	state, err := iac.PullState(ctx, fm.targetConfigDir)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		var version struct {
+			Serial  int64  `json:"serial"`
+			Lineage string `json:"lineage"`
+		}
+		if err = json.Unmarshal(state, &version); err != nil {
+			return nil, fmt.Errorf("failed to parse state: %w", err)
+		}
+		snapshot.HasState = true
+		snapshot.StateSerial, snapshot.StateLineage = version.Serial, version.Lineage
+	}
+
+	if snapshot.Mappings, err = mockDynamo.GetTargetMappings(target.OrgId); err != nil {
+		return nil, err
+	}
+
+	if latest := latestSnapshot(ctx, fm); latest != nil && latest.sameAs(snapshot) {
+		log.Printf("Org '%s' is unchanged since snapshot '%s'. Skipping its backup", target.OrgId, latest.Id)
+		return latest, nil
+	}
+
+	for _, file := range snapshot.Files {
+		if err = fm.store.Write(ctx, path.Join(snapshotPrefix, snapshotConfigDir, file), files[file]); err != nil {
+			return nil, err
+		}
+	}
+	if state != nil {
+		if err = fm.store.Write(ctx, path.Join(snapshotPrefix, snapshotStateFile), state); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = fm.store.Write(ctx, path.Join(snapshotPrefix, snapshotMetadataFile), data); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Backed up org '%s' to '%s' in %s", target.OrgId, snapshotPrefix, fm.store.Name())
+
+	if err = pruneSnapshots(ctx, fm, m.OrgManager.Backups.Retain); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// sameAs returns true if other holds the same executor, files, state version and mappings as s
+func (s *Snapshot) sameAs(other *Snapshot) bool {
+	return s.Executor == other.Executor &&
+		slices.Equal(s.Files, other.Files) &&
+		maps.Equal(s.Checksums, other.Checksums) &&
+		s.HasState == other.HasState &&
+		s.StateSerial == other.StateSerial &&
+		s.StateLineage == other.StateLineage &&
+		slices.Equal(s.Mappings, other.Mappings)
+}
+
+// latestSnapshot returns the newest snapshot of the target org of fm, or nil if there is none or it cannot be read.
+// Snapshot IDs start with their timestamp, so the newest one is the greatest.
+func latestSnapshot(ctx context.Context, fm *FileManager) *Snapshot {
+	keys, err := fm.store.List(ctx, fm.backupsPrefix+"/")
+	if err != nil {
+		log.Printf("Failed to list the snapshots of '%s'. Error: %s", fm.backupsPrefix, err.Error())
+		return nil
+	}
+
+	var latestId string
+	for _, key := range keys {
+		if id := path.Base(path.Dir(key)); path.Base(key) == snapshotMetadataFile && id > latestId {
+			latestId = id
+		}
+	}
+	if latestId == "" {
+		return nil
+	}
+	snapshot, err := readSnapshot(ctx, fm, latestId)
+	if err != nil {
+		log.Printf("Failed to read the latest snapshot. Error: %s", err.Error())
+		return nil
+	}
+	return snapshot
+}
+
+// pruneSnapshots deletes the oldest snapshots of the target org of fm, keeping the newest retain snapshots. A retain
+// of zero or less keeps the default number of snapshots.
+func pruneSnapshots(ctx context.Context, fm *FileManager, retain int) error {
+	if retain <= 0 {
+		retain = defaultRetainedSnapshots
+	}
+
+	snapshots, err := listSnapshots(ctx, fm)
+	if err != nil || len(snapshots) <= retain {
+		return err
+	}
+
+	for _, snapshot := range snapshots[:len(snapshots)-retain] {
+		keys, err := fm.store.List(ctx, path.Join(fm.backupsPrefix, snapshot.Id)+"/")
+		if err != nil {
+			return err
+		}
+		// the metadata file goes last, so that a partly deleted snapshot is still listed and deleted next time
+		sort.SliceStable(keys, func(i, j int) bool {
+			return path.Base(keys[j]) == snapshotMetadataFile && path.Base(keys[i]) != snapshotMetadataFile
+		})
+		for _, key := range keys {
+			if err = fm.store.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		log.Printf("Deleted snapshot '%s' of org '%s'", snapshot.Id, snapshot.OrgId)
+	}
+	return nil
+}
+
+// ListSnapshots returns the snapshots of the given target org, oldest first
+func ListSnapshots(ctx context.Context, credentialsFilePath, orgId string) ([]Snapshot, error) {
+	m, _, err := newMaintenanceMrMo(credentialsFilePath, orgId)
+	if err != nil {
+		return nil, err
+	}
+	return listSnapshots(ctx, m.newFileManager(orgId))
+}
+
+// listSnapshots returns the snapshots in the backups of the target org of fm, oldest first. Snapshots that cannot be
+// read are skipped.
+func listSnapshots(ctx context.Context, fm *FileManager) ([]Snapshot, error) {
+	keys, err := fm.store.List(ctx, fm.backupsPrefix+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, key := range keys {
+		if path.Base(key) != snapshotMetadataFile {
+			continue
+		}
+		snapshot, err := readSnapshot(ctx, fm, path.Base(path.Dir(key)))
+		if err != nil {
+			log.Printf("Skipping '%s'. Error: %s", key, err.Error())
+			continue
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// RestoreSnapshot rolls a target org back to a snapshot.
+//
+// Parameters:
+//   - ctx: Context bounding the runs of the executor and the requests to the config store
+//   - credentialsFilePath: The credentials file, which configures the executor, state backend and config store
+//   - orgId: The ID of the target org
+//   - snapshotId: The ID of the snapshot to restore
+//
+// Returns:
+//   - diag.Diagnostics: Collection of any diagnostic messages or errors encountered
+//
+// See restoreSnapshot for the steps of a restore.
+func RestoreSnapshot(ctx context.Context, credentialsFilePath, orgId, snapshotId string) diag.Diagnostics {
+	m, target, err := newMaintenanceMrMo(credentialsFilePath, orgId)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return m.restoreSnapshot(ctx, target, snapshotId)
+}
+
+// restoreSnapshot rolls the target org back to the snapshot with the given ID.
+//
+// The function performs the following steps:
+//  1. Reads the snapshot's files and state from the config store, so that nothing is changed if any of them is missing
+//  2. Backs up the current state of the org, so that the restore itself can be undone
+//  3. Replaces the *.tf.json files, layout manifest and artifacts of the config directory with the ones in the snapshot
+//  4. Pushes the state in the snapshot, replacing the current state
+//  5. Replaces the org's entries in the mapping table with the ones in the snapshot
+//
+// Entities are not changed in the org itself. The next apply of each entity reconciles it with the restored state.
+func (m *MrMo) restoreSnapshot(ctx context.Context, target orgManager.OrgData, snapshotId string) (diags diag.Diagnostics) {
+	m.MessageId = "restore-" + snapshotId
+	iac, err := m.getExecutor()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	fm := m.newFileManager(target.OrgId)
+	snapshot, err := readSnapshot(ctx, fm, snapshotId)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if snapshot.HasState && snapshot.Executor != iac.Name() {
+		return diag.Errorf("snapshot '%s' holds %s state, but the configured executor is %s", snapshotId, snapshot.Executor, iac.Name())
+	}
+
+	snapshotPrefix := path.Join(fm.backupsPrefix, snapshotId)
+	files := make(map[string][]byte, len(snapshot.Files))
+	for _, file := range snapshot.Files {
+		// the snapshot is read from the config store, so its paths are not trusted
+		if _, err = configDirPath(fm.targetConfigDir, file); err != nil {
+			return diag.FromErr(err)
+		}
+		if files[file], err = fm.store.Read(ctx, path.Join(snapshotPrefix, snapshotConfigDir, file)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	var state []byte
+	if snapshot.HasState {
+		if state, err = fm.store.Read(ctx, path.Join(snapshotPrefix, snapshotStateFile)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	diags = append(diags, fm.pull(ctx)...)
+	if diags.HasError() {
+		return diags
+	}
+	if _, err = m.backupTarget(ctx, fm, target); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	// configs
+	currentFiles, err := snapshotConfigFiles(fm.targetConfigDir)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	for _, file := range currentFiles {
+		if _, ok := files[file]; ok {
+			// overwritten below
+			continue
+		}
+		filePath := filepath.Join(fm.targetConfigDir, filepath.FromSlash(file))
+		if err = os.Remove(filePath); err != nil {
+			return append(diags, diag.Errorf("failed to delete file '%s'. Error: %s", filePath, err.Error())...)
+		}
+		diags = append(diags, fm.unpush(ctx, filePath)...)
+	}
+	for _, file := range snapshot.Files {
+		filePath := filepath.Join(fm.targetConfigDir, filepath.FromSlash(file))
+		if err = writeChecksummedFile(filePath, files[file]); err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		diags = append(diags, fm.push(ctx, filePath)...)
+	}
+	if diags.HasError() {
+		return diags
+	}
+
+	// state
+	diags = append(diags, iac.Init(ctx, fm.targetConfigDir)...)
+	if diags.HasError() {
+		return diags
+	}
+	if snapshot.HasState {
+		diags = append(diags, iac.PushState(ctx, fm.targetConfigDir, state)...)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	// mappings
+	if err = restoreMappings(target.OrgId, snapshot.Mappings); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	log.Printf("Restored org '%s' to snapshot '%s'", target.OrgId, snapshotId)
+	return diags
+}
+
+// newMaintenanceMrMo returns a MrMo for commands that work on a target org's config directory without processing a
+// message, along with the target org. The executor is not created.
+func newMaintenanceMrMo(credentialsFilePath, orgId string) (*MrMo, orgManager.OrgData, error) {
+	credData, err := orgManager.ParseCredentialData(credentialsFilePath)
+	if err != nil {
+		return nil, orgManager.OrgData{}, err
+	}
+
+	for _, target := range credData.Targets {
+		if target.OrgId != orgId {
+			continue
+		}
+		m := &MrMo{OrgManager: credData}
+		if m.ConfigStore, err = configStore.New(credData.Storage); err != nil {
+			return nil, target, err
+		}
+		return m, target, nil
+	}
+	return nil, orgManager.OrgData{}, fmt.Errorf("org '%s' is not a target in '%s'", orgId, credentialsFilePath)
+}
+
+// restoreMappings makes the org's entries in the mapping table match mappings
+func restoreMappings(orgId string, mappings []mockDynamo.Mapping) error {
+	current, err := mockDynamo.GetTargetMappings(orgId)
+	if err != nil {
+		return err
+	}
+
+	restored := make(map[string]bool)
+	for _, mapping := range mappings {
+		restored[mapping.SourceEntityId] = true
+	}
+	for _, mapping := range current {
+		if restored[mapping.SourceEntityId] {
+			continue
+		}
+		if err = mockDynamo.DeleteTargetInfo(mapping.SourceEntityId, orgId); err != nil {
+			return err
+		}
+	}
+
+	for _, mapping := range mappings {
+		if err = mockDynamo.UpdateItem(mapping.ResourceType, mapping.SourceEntityId, orgId, mapping.TargetEntityId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotConfigFiles returns the paths, relative to dir and separated by slashes, of the files of the config
+// directory that are snapshotted: its *.tf.json files, the layout manifest, and the artifact manifests along with the
+// artifacts they list
+func snapshotConfigFiles(dir string) ([]string, error) {
+	configs, err := filepath.Glob(filepath.Join(dir, "*.tf.json"))
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := filepath.Glob(filepath.Join(dir, "*"+artifactManifestSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range append(configs, manifests...) {
+		files = append(files, filepath.Base(file))
+	}
+	if fileExists(filepath.Join(dir, layoutManifestFile)) {
+		files = append(files, layoutManifestFile)
+	}
+
+	listed := make(map[string]bool)
+	for _, manifest := range manifests {
+		artifacts, err := readArtifactManifestFile(manifest)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range artifacts {
+			artifactPath, err := configDirPath(dir, a.RelativePath)
+			if err != nil {
+				return nil, err
+			}
+			relativePath, err := filepath.Rel(dir, artifactPath)
+			if err != nil {
+				return nil, err
+			}
+			// artifacts may be shared by several entities, and are snapshotted once
+			if relativePath = filepath.ToSlash(relativePath); !listed[relativePath] && fileExists(artifactPath) {
+				listed[relativePath] = true
+				files = append(files, relativePath)
+			}
+		}
+	}
+	return files, nil
+}
+
+func readSnapshot(ctx context.Context, fm *FileManager, snapshotId string) (*Snapshot, error) {
+	data, err := fm.store.Read(ctx, path.Join(fm.backupsPrefix, snapshotId, snapshotMetadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot '%s': %w", snapshotId, err)
+	}
+	var snapshot Snapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot '%s': %w", snapshotId, err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,306 @@
+package mrmo
+
+import (
+	"context"
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUnitBackupAndRestoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	target := orgManager.OrgData{OrgId: testOrgId}
+	mockDynamo.UseTestTable(t, mappingItem(testResourceType, "source-1", target.OrgId, "target-1"))
+
+	fake := &fakeExecutor{rawState: []byte(`{"serial":1}`)}
+	m := newTestMrMo(t)
+	m.Executor = fake
+	fm := m.newFileManager(target.OrgId)
+	writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{"version":1}`)
+
+	snapshot, err := m.backupTarget(ctx, fm, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.HasState || !reflect.DeepEqual(snapshot.Files, []string{"source-1.tf.json"}) || len(snapshot.Mappings) != 1 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	// the message changes the config, adds an entity, moves the state on and changes the mappings
+	writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{"version":2}`)
+	writeTestFile(t, fm.targetConfigDir, "source-2.tf.json", `{"version":1}`)
+	fake.rawState = []byte(`{"serial":2}`)
+	if err = mockDynamo.UpdateItem(testResourceType, "source-2", target.OrgId, "target-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = mockDynamo.UpdateItem(testResourceType, "source-1", target.OrgId, "target-1b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := m.restoreSnapshot(ctx, target, snapshot.Id); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if content := readTestFile(t, fm.targetConfigDir, "source-1.tf.json"); content != `{"version":1}` {
+		t.Errorf("expected the config of source-1 to be restored, got %s", content)
+	}
+	if fileExists(filepath.Join(fm.targetConfigDir, "source-2.tf.json")) {
+		t.Error("expected the config of source-2, added after the snapshot, to be removed")
+	}
+	if err = verifyChecksums(fm.targetConfigDir); err != nil {
+		t.Errorf("expected the restored configs to match their checksums: %v", err)
+	}
+	if string(fake.rawState) != `{"serial":1}` {
+		t.Errorf("expected the state to be restored, got %s", fake.rawState)
+	}
+
+	mappings, err := mockDynamo.GetTargetMappings(target.OrgId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []mockDynamo.Mapping{{ResourceType: testResourceType, SourceEntityId: "source-1", TargetEntityId: "target-1"}}
+	if !reflect.DeepEqual(mappings, expected) {
+		t.Errorf("expected mappings %v, got %v", expected, mappings)
+	}
+
+	// the restore snapshotted the org first, so it can be undone
+	snapshots, err := listSnapshots(ctx, fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Id != snapshot.Id || !reflect.DeepEqual(snapshots[1].Files, []string{"source-1.tf.json", "source-2.tf.json"}) {
+		t.Errorf("expected the snapshot and a snapshot of the org before the restore, got %+v", snapshots)
+	}
+}
+
+func TestUnitRestoreIncompleteSnapshot(t *testing.T) {
+	ctx := context.Background()
+	target := orgManager.OrgData{OrgId: testOrgId}
+	mockDynamo.UseTestTable(t)
+
+	m := newTestMrMo(t)
+	fm := m.newFileManager(target.OrgId)
+	writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{"version":1}`)
+
+	snapshot, err := m.backupTarget(ctx, fm, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fm.store.Delete(ctx, path.Join(fm.backupsPrefix, snapshot.Id, snapshotConfigDir, "source-1.tf.json")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{"version":2}`)
+
+	if diags := m.restoreSnapshot(ctx, target, snapshot.Id); !diags.HasError() {
+		t.Fatal("expected an error restoring a snapshot with a missing file")
+	}
+	if content := readTestFile(t, fm.targetConfigDir, "source-1.tf.json"); content != `{"version":2}` {
+		t.Errorf("expected the current config to be left in place, got %s", content)
+	}
+}
+
+func TestUnitBackupAndRestoreArtifacts(t *testing.T) {
+	ctx := context.Background()
+	target := orgManager.OrgData{OrgId: testOrgId}
+	mockDynamo.UseTestTable(t)
+
+	m := newTestMrMo(t)
+	fm := m.newFileManager(target.OrgId)
+	writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{}`)
+	writeTestFile(t, fm.targetConfigDir, "flows/inbound.yaml", "version: 1")
+	writeTestFile(t, fm.targetConfigDir, "source-1.artifacts.json", `[{"relativePath":"flows/inbound.yaml"}]`)
+
+	snapshot, err := m.backupTarget(ctx, fm, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []string{"source-1.tf.json", "source-1.artifacts.json", "flows/inbound.yaml"}
+	if !reflect.DeepEqual(snapshot.Files, expectedFiles) {
+		t.Fatalf("expected files %v to be snapshotted, got %v", expectedFiles, snapshot.Files)
+	}
+
+	// the message changes the flow and places another entity's artifact
+	writeTestFile(t, fm.targetConfigDir, "flows/inbound.yaml", "version: 2")
+	writeTestFile(t, fm.targetConfigDir, "flows/outbound.yaml", "version: 1")
+	writeTestFile(t, fm.targetConfigDir, "source-2.artifacts.json", `[{"relativePath":"flows/outbound.yaml"}]`)
+
+	if diags := m.restoreSnapshot(ctx, target, snapshot.Id); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if content := readTestFile(t, fm.targetConfigDir, "flows/inbound.yaml"); content != "version: 1" {
+		t.Errorf("expected the flow of source-1 to be restored, got %s", content)
+	}
+	for _, file := range []string{"flows/outbound.yaml", "source-2.artifacts.json"} {
+		if fileExists(filepath.Join(fm.targetConfigDir, file)) {
+			t.Errorf("expected '%s', placed after the snapshot, to be removed", file)
+		}
+	}
+	if err = verifyChecksums(fm.targetConfigDir); err != nil {
+		t.Errorf("expected the restored files to match their checksums: %v", err)
+	}
+}
+
+func TestUnitBackupSkipsUnchangedTarget(t *testing.T) {
+	ctx := context.Background()
+	target := orgManager.OrgData{OrgId: testOrgId}
+	mockDynamo.UseTestTable(t)
+
+	fake := &fakeExecutor{rawState: []byte(`{"serial":1,"lineage":"lineage-1"}`)}
+	m := newTestMrMo(t)
+	m.Executor = fake
+	fm := m.newFileManager(target.OrgId)
+	writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{"version":1}`)
+
+	first, err := m.backupTarget(ctx, fm, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.StateSerial != 1 || first.StateLineage != "lineage-1" {
+		t.Fatalf("expected the state version to be recorded, got %+v", first)
+	}
+
+	m.MessageId = "message-2"
+	unchanged, err := m.backupTarget(ctx, fm, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged.Id != first.Id {
+		t.Errorf("expected the latest snapshot '%s' to be returned for an unchanged org, got '%s'", first.Id, unchanged.Id)
+	}
+
+	changes := map[string]func(){
+		"state serial": func() { fake.rawState = []byte(`{"serial":2,"lineage":"lineage-1"}`) },
+		"config":       func() { writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{"version":2}`) },
+		"mappings": func() {
+			if err := mockDynamo.UpdateItem(testResourceType, "source-1", target.OrgId, "target-1"); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+	latestId := first.Id
+	for name, change := range changes {
+		change()
+		snapshot, err := m.backupTarget(ctx, fm, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snapshot.Id == latestId {
+			t.Errorf("expected a change to the %s to be snapshotted", name)
+		}
+		latestId = snapshot.Id
+	}
+
+	if snapshots, err := listSnapshots(ctx, fm); err != nil || len(snapshots) != 1+len(changes) {
+		t.Errorf("expected %d snapshots, got %d (%v)", 1+len(changes), len(snapshots), err)
+	}
+}
+
+func TestUnitPruneSnapshots(t *testing.T) {
+	ctx := context.Background()
+	target := orgManager.OrgData{OrgId: testOrgId}
+	mockDynamo.UseTestTable(t)
+
+	fake := &fakeExecutor{}
+	m := newTestMrMo(t)
+	m.Executor = fake
+	m.OrgManager.Backups.Retain = 2
+	fm := m.newFileManager(target.OrgId)
+	writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{}`)
+
+	// a retried message is backed up again once it moved the state on, without overwriting its previous snapshots
+	var ids []string
+	for i := 0; i < 3; i++ {
+		fake.rawState = []byte(fmt.Sprintf(`{"serial":%d}`, i))
+		snapshot, err := m.backupTarget(ctx, fm, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, snapshot.Id)
+	}
+
+	snapshots, err := listSnapshots(ctx, fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Id != ids[1] || snapshots[1].Id != ids[2] {
+		t.Fatalf("expected the two newest snapshots %v to be kept, got %+v", ids[1:], snapshots)
+	}
+	if keys, _ := fm.store.List(ctx, path.Join(fm.backupsPrefix, ids[0])+"/"); len(keys) != 0 {
+		t.Errorf("expected every file of the oldest snapshot to be deleted, got %v", keys)
+	}
+}
+
+func TestUnitRestoreMappings(t *testing.T) {
+	mockDynamo.UseTestTable(t,
+		mappingItem(testResourceType, "source-1", "org-a", "target-1b"),
+		mappingItem(testResourceType, "source-2", "org-a", "target-2"),
+		mappingItem(testResourceType, "source-3", "org-b", "target-3"),
+	)
+
+	restored := []mockDynamo.Mapping{
+		{ResourceType: testResourceType, SourceEntityId: "source-1", TargetEntityId: "target-1"},
+		{ResourceType: testResourceType, SourceEntityId: "source-4", TargetEntityId: "target-4"},
+	}
+	if err := restoreMappings("org-a", restored); err != nil {
+		t.Fatal(err)
+	}
+
+	mappings, err := mockDynamo.GetTargetMappings("org-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mappings, restored) {
+		t.Errorf("expected mappings %v, got %v", restored, mappings)
+	}
+	if targetId, err := mockDynamo.GetTargetIdBySourceId("source-3", "org-b"); err != nil || targetId != "target-3" {
+		t.Errorf("expected the mappings of other orgs to be kept, got '%s' (%v)", targetId, err)
+	}
+}
+
+func TestUnitListSnapshots(t *testing.T) {
+	ctx := context.Background()
+	mockDynamo.UseTestTable(t)
+
+	dir := t.TempDir()
+	credentialsFilePath := filepath.Join(dir, "creds.yml")
+	credentials := "targets:\n  - orgId: org-a\nstorage:\n  root: " + filepath.Join(dir, "store") + "\n"
+	if err := os.WriteFile(credentialsFilePath, []byte(credentials), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, target, err := newMaintenanceMrMo(credentialsFilePath, "org-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Executor = &fakeExecutor{}
+	fm := m.newFileManager(target.OrgId)
+
+	for _, messageId := range []string{"message-1", "message-2"} {
+		m.MessageId = messageId
+		writeTestFile(t, fm.targetConfigDir, "source-1.tf.json", `{"message":"`+messageId+`"}`)
+		if _, err = m.backupTarget(ctx, fm, target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// files that are not snapshot metadata are ignored
+	if err = fm.store.Write(ctx, path.Join(fm.backupsPrefix, "notes.txt"), []byte("notes")); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := ListSnapshots(ctx, credentialsFilePath, "org-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].MessageId != "message-1" || snapshots[1].MessageId != "message-2" {
+		t.Errorf("expected the snapshots of message-1 and message-2, oldest first, got %+v", snapshots)
+	}
+	if _, err = ListSnapshots(ctx, credentialsFilePath, "org-b"); err == nil {
+		t.Error("expected an error listing the snapshots of an org that is not a target")
+	}
+}
@@ -0,0 +1,196 @@
+package mrmo
+
+import (
+	"context"
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"log"
+	"sort"
+)
+
+// BootstrapNameKey pairs entities by their name attribute. Any other key is treated as an attribute path in the
+// exported config of each entity.
+const BootstrapNameKey = "name"
+
+// BootstrapPair is a proposed mapping between an entity in the source org and an existing entity in a target org
+type BootstrapPair struct {
+	ResourceType   string
+	Key            string
+	SourceEntityId string
+	TargetOrgId    string
+	TargetOrgName  string
+	TargetEntityId string
+}
+
+// Bootstrap pairs entities that already exist in both the source org and the target orgs, so that they can be added
+// to the mapping table and become managed by Mr Mo without being recreated.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - credentialsFilePath: Path to the credentials file describing the source and target orgs
+//   - resourceTypes: The resource types to pair entities for
+//   - matchKey: BootstrapNameKey, or an attribute path whose value identifies an entity in every org
+//
+// Returns:
+//   - []BootstrapPair: The proposed pairs. Nothing is written to the mapping table; see ApplyBootstrapPairs.
+//   - diag.Diagnostics: Warnings for entities that could not be paired unambiguously, and any errors encountered
+//
+// Source entities that already have a mapping for a target org are not proposed again, and target entities that are
+// already mapped to another source entity are not proposed at all.
+func Bootstrap(ctx context.Context, credentialsFilePath string, resourceTypes []string, matchKey string) (pairs []BootstrapPair, diags diag.Diagnostics) {
+	for _, resourceType := range resourceTypes {
+		m, err := newMrMo(resourceType, credentialsFilePath, "")
+		if err != nil {
+			return pairs, append(diags, diag.FromErr(err)...)
+		}
+
+		sourceKeys, err := m.getEntityKeys(ctx, m.OrgManager.Source, matchKey)
+		if err != nil {
+			return pairs, append(diags, diag.FromErr(err)...)
+		}
+
+		for _, target := range m.OrgManager.Targets {
+			targetKeys, err := m.getEntityKeys(ctx, target, matchKey)
+			if err != nil {
+				return pairs, append(diags, diag.FromErr(err)...)
+			}
+
+			matched, pairDiags := pairEntitiesByKey(sourceKeys, targetKeys)
+			diags = append(diags, pairDiags...)
+
+			proposed, proposeDiags := proposeBootstrapPairs(resourceType, matchKey, matched, target)
+			pairs = append(pairs, proposed...)
+			diags = append(diags, proposeDiags...)
+			if diags.HasError() {
+				return pairs, diags
+			}
+		}
+	}
+	return pairs, diags
+}
+
+// proposeBootstrapPairs turns the matched entities of a target org into proposed pairs. Source entities that are
+// already mapped in the org are skipped, with a warning if they are mapped to a different entity than the one they
+// matched. Target entities that are already mapped to another source entity are skipped with a warning, so that no
+// entity is managed twice.
+func proposeBootstrapPairs(resourceType, matchKey string, matched []entityPair, target orgManager.OrgData) (pairs []BootstrapPair, diags diag.Diagnostics) {
+	for _, p := range matched {
+		if existingTargetId, err := mockDynamo.GetTargetIdBySourceId(p.sourceId, target.OrgId); err == nil {
+			if existingTargetId != p.targetId {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  fmt.Sprintf("Existing mapping conflicts with proposed %s pair", resourceType),
+					Detail: fmt.Sprintf("Source entity '%s' is already mapped to '%s' in org '%s', but matched '%s' by %s '%s'.",
+						p.sourceId, existingTargetId, target.OrgId, p.targetId, matchKey, p.key),
+				})
+			}
+			continue
+		}
+
+		mappedSourceId, err := mockDynamo.GetSourceIdByTargetId(p.targetId, target.OrgId)
+		if err != nil {
+			return pairs, append(diags, diag.FromErr(err)...)
+		}
+		if mappedSourceId != "" {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Target %s is already mapped", resourceType),
+				Detail: fmt.Sprintf("'%s' in org '%s' matched source entity '%s' by %s '%s', but is already mapped to source entity '%s'.",
+					p.targetId, target.OrgId, p.sourceId, matchKey, p.key, mappedSourceId),
+			})
+			continue
+		}
+
+		pairs = append(pairs, BootstrapPair{
+			ResourceType:   resourceType,
+			Key:            p.key,
+			SourceEntityId: p.sourceId,
+			TargetOrgId:    target.OrgId,
+			TargetOrgName:  target.Name,
+			TargetEntityId: p.targetId,
+		})
+	}
+	return pairs, diags
+}
+
+// ApplyBootstrapPairs writes the reviewed pairs into the mapping table
+func ApplyBootstrapPairs(pairs []BootstrapPair) error {
+	for _, p := range pairs {
+		if err := mockDynamo.UpdateItem(p.ResourceType, p.SourceEntityId, p.TargetOrgId, p.TargetEntityId); err != nil {
+			return fmt.Errorf("failed to write mapping for %s '%s' in org '%s': %w", p.ResourceType, p.SourceEntityId, p.TargetOrgId, err)
+		}
+		log.Printf("Mapped %s '%s' to '%s' in org '%s'", p.ResourceType, p.SourceEntityId, p.TargetEntityId, p.TargetOrgId)
+	}
+	return nil
+}
+
+// getEntityKeys returns the match key of every entity of the MrMo resource type in org, keyed by entity ID. The labels
+// the exporter gives entities are sanitized names, so keys are always read from the exported configs, which come from a
+// single export of the type in org.
+func (m *MrMo) getEntityKeys(ctx context.Context, org orgManager.OrgData, matchKey string) (map[string]string, error) {
+	entities, err := m.getEntities(ctx, m.ResourceType, org)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string)
+	for id := range entities {
+		key, err := m.getEntityAttribute(ctx, m.ResourceType, id, org, matchKey)
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			keys[id] = key
+		}
+	}
+	return keys, nil
+}
+
+type entityPair struct {
+	key      string
+	sourceId string
+	targetId string
+}
+
+// pairEntitiesByKey pairs source and target entities that share the same key. Keys that are used by more than one
+// entity in either org are reported as warnings and left unpaired.
+func pairEntitiesByKey(sourceKeys, targetKeys map[string]string) (pairs []entityPair, diags diag.Diagnostics) {
+	sourceIdsByKey := groupIdsByKey(sourceKeys)
+	targetIdsByKey := groupIdsByKey(targetKeys)
+
+	keys := make([]string, 0, len(sourceIdsByKey))
+	for key := range sourceIdsByKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		sourceIds, targetIds := sourceIdsByKey[key], targetIdsByKey[key]
+		if len(targetIds) == 0 {
+			continue
+		}
+		if len(sourceIds) > 1 || len(targetIds) > 1 {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Ambiguous key '%s'", key),
+				Detail:   fmt.Sprintf("Key '%s' is shared by source entities %v and target entities %v. These will not be paired.", key, sourceIds, targetIds),
+			})
+			continue
+		}
+		pairs = append(pairs, entityPair{key: key, sourceId: sourceIds[0], targetId: targetIds[0]})
+	}
+	return
+}
+
+func groupIdsByKey(keys map[string]string) map[string][]string {
+	grouped := make(map[string][]string)
+	for id, key := range keys {
+		grouped[key] = append(grouped[key], id)
+	}
+	for key := range grouped {
+		sort.Strings(grouped[key])
+	}
+	return grouped
+}
@@ -0,0 +1,112 @@
+package mrmo
+
+import (
+	"context"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"testing"
+)
+
+func TestUnitPairEntitiesByKey(t *testing.T) {
+	sourceKeys := map[string]string{
+		"source-1": "Sales",
+		"source-2": "Support",
+		"source-3": "Billing",
+		"source-4": "Billing",
+		"source-5": "Only In Source",
+	}
+	targetKeys := map[string]string{
+		"target-1": "Sales",
+		"target-2": "Support",
+		"target-3": "Support",
+		"target-4": "Billing",
+		"target-5": "Only In Target",
+	}
+
+	pairs, diags := pairEntitiesByKey(sourceKeys, targetKeys)
+
+	if len(pairs) != 1 {
+		t.Fatalf("Expected 1 pair, got %d: %v", len(pairs), pairs)
+	}
+	if pairs[0].sourceId != "source-1" || pairs[0].targetId != "target-1" || pairs[0].key != "Sales" {
+		t.Errorf("Unexpected pair %v", pairs[0])
+	}
+
+	// Billing is ambiguous in the source org and Support is ambiguous in the target org
+	if len(diags) != 2 || diags.HasError() {
+		t.Errorf("Expected 2 warnings, got %v", diags)
+	}
+}
+
+func TestUnitProposeBootstrapPairs(t *testing.T) {
+	const resourceType = "genesyscloud_routing_skill"
+	target := orgManager.OrgData{OrgId: "org-a", Name: "Org A"}
+	mockDynamo.UseTestTable(t,
+		mappingItem(resourceType, "source-1", "org-a", "target-1"),
+		mappingItem(resourceType, "source-4", "org-a", "target-other"),
+		mappingItem(resourceType, "source-9", "org-a", "target-2"),
+	)
+
+	matched := []entityPair{
+		{key: "Already Mapped", sourceId: "source-1", targetId: "target-1"},
+		{key: "Taken", sourceId: "source-2", targetId: "target-2"},
+		{key: "New", sourceId: "source-3", targetId: "target-3"},
+		{key: "Conflicting", sourceId: "source-4", targetId: "target-4"},
+	}
+	pairs, diags := proposeBootstrapPairs(resourceType, BootstrapNameKey, matched, target)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if len(pairs) != 1 || pairs[0].SourceEntityId != "source-3" || pairs[0].TargetEntityId != "target-3" {
+		t.Errorf("expected only source-3 -> target-3 to be proposed, got %+v", pairs)
+	}
+	if len(diags) != 2 {
+		t.Fatalf("expected warnings for the taken target and the conflicting mapping, got %v", diags)
+	}
+	if diags[0].Summary != "Target "+resourceType+" is already mapped" {
+		t.Errorf("unexpected warning for the taken target: %v", diags[0])
+	}
+}
+
+func TestUnitGetEntityKeysUsesNames(t *testing.T) {
+	const resourceType = "genesyscloud_routing_skill"
+	m := &MrMo{ResourceType: resourceType, OrgManager: &orgManager.OrgManager{Source: orgManager.OrgData{OrgId: "source-org"}}}
+	withCachedEntities(m, "source-org", resourceType, map[string]string{"source-1": "Foo Bar"})
+	withCachedEntities(m, "org-a", resourceType, map[string]string{"target-1": "Foo_Bar", "target-2": "Foo Bar"})
+
+	sourceKeys, err := m.getEntityKeys(context.Background(), m.OrgManager.Source, BootstrapNameKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetKeys, err := m.getEntityKeys(context.Background(), orgManager.OrgData{OrgId: "org-a"}, BootstrapNameKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, diags := pairEntitiesByKey(sourceKeys, targetKeys)
+	if len(diags) != 0 {
+		t.Errorf("expected names that share a label not to be ambiguous, got %v", diags)
+	}
+	if len(pairs) != 1 || pairs[0].targetId != "target-2" {
+		t.Errorf("expected source-1 to pair with target-2 by name, got %+v", pairs)
+	}
+}
+
+func TestUnitGetEntityKeysUsesExportedAttribute(t *testing.T) {
+	const resourceType = "genesyscloud_routing_skill"
+	org := orgManager.OrgData{OrgId: "org-a"}
+	m := withCachedEntityConfigs(&MrMo{ResourceType: resourceType}, org.OrgId, resourceType, map[string]util.JsonMap{
+		"target-1": {"name": "Sales", "settings": []any{map[string]any{"code": "S1"}}},
+		"target-2": {"name": "Support"},
+	})
+
+	keys, err := m.getEntityKeys(context.Background(), org, "settings.code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys["target-1"] != "S1" {
+		t.Errorf("expected only target-1 to have the key 'S1', got %v", keys)
+	}
+}
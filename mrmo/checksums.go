@@ -0,0 +1,167 @@
+package mrmo
+
+import (
+	"fmt"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// checksumSuffix is appended to the name of a config file to get the name of its checksum sidecar. Sidecars hold
+	// the sha256 checksum of the file in the format of sha256sum, so they can also be checked with `sha256sum -c`.
+	checksumSuffix = ".sha256"
+	// tmpSuffix ends the names of the temporary files that writes go through
+	tmpSuffix = configStore.TmpSuffix
+)
+
+// writeFile atomically writes data to filePath
+func writeFile(filePath string, data []byte) error {
+	return writeFileAtomic(filePath, false, func(tmpPath string) error {
+		return os.WriteFile(tmpPath, data, 0644)
+	})
+}
+
+// writeChecksummedFile atomically writes data to filePath and records its checksum in the file's sidecar
+func writeChecksummedFile(filePath string, data []byte) error {
+	return writeFileAtomic(filePath, true, func(tmpPath string) error {
+		return os.WriteFile(tmpPath, data, 0644)
+	})
+}
+
+// writeFileAtomic writes filePath so that it holds either its previous or its new content, even if Mr Mo crashes
+// midway (see configStore.WriteFileAtomic). If withChecksum is set, the file's sidecar records the checksum of the new
+// content along with the checksum of the previous content before the new content replaces filePath, and only the
+// checksum of the new content once it has. A crash in between leaves a pending sidecar, which verifyChecksums accepts
+// for either content.
+func writeFileAtomic(filePath string, withChecksum bool, write func(tmpPath string) error) error {
+	var (
+		beforeRename func(data []byte) error
+		newChecksum  string
+		pending      bool
+	)
+	if withChecksum {
+		beforeRename = func(data []byte) error {
+			newChecksum = checksum(data)
+			checksums := []string{newChecksum}
+			if previous, err := os.ReadFile(filePath); err == nil && checksum(previous) != newChecksum {
+				checksums = append(checksums, checksum(previous))
+				pending = true
+			}
+			return writeChecksumSidecar(filePath, checksums...)
+		}
+	}
+
+	if err := configStore.WriteFileAtomic(filePath, write, beforeRename); err != nil {
+		return fmt.Errorf("failed to write file '%s': %w", filePath, err)
+	}
+	if pending {
+		if err := writeChecksumSidecar(filePath, newChecksum); err != nil {
+			return fmt.Errorf("failed to write file '%s': %w", filePath, err)
+		}
+	}
+	return nil
+}
+
+// writeChecksumSidecar atomically writes the sidecar of filePath, with a line in the format of sha256sum for each of
+// checksums. A sidecar with more than one line is pending: the file is being replaced and may hold any of them.
+func writeChecksumSidecar(filePath string, checksums ...string) error {
+	var sidecar strings.Builder
+	for _, sum := range checksums {
+		sidecar.WriteString(fmt.Sprintf("%s  %s\n", sum, filepath.Base(filePath)))
+	}
+	return writeFile(filePath+checksumSuffix, []byte(sidecar.String()))
+}
+
+// readChecksumSidecar returns the checksums recorded in the sidecar at sidecarPath
+func readChecksumSidecar(sidecarPath string) ([]string, error) {
+	data, err := os.ReadFile(sidecarPath)
+	if err != nil {
+		return nil, err
+	}
+	var checksums []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			checksums = append(checksums, fields[0])
+		}
+	}
+	return checksums, nil
+}
+
+// removeChecksum removes the checksum sidecar of filePath, if there is one
+func removeChecksum(filePath string) error {
+	if err := os.Remove(filePath + checksumSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file '%s': %w", filePath+checksumSuffix, err)
+	}
+	return nil
+}
+
+// verifyChecksums checks every file in dir that has a checksum sidecar against its checksum, and removes the temporary
+// files left behind by interrupted writes. A file with a pending sidecar may match any of its checksums. Files written
+// by tofu (.terraform), Mr Mo (.mrmo) and git are skipped, as are sidecars of files that no longer exist.
+//
+// Returns an error listing the corrupted files, if any.
+func verifyChecksums(dir string) error {
+	var corrupted []string
+
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == dir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if filePath != dir && generatedDirs[name] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasPrefix(name, ".") && strings.HasSuffix(name, tmpSuffix) {
+			log.Printf("Removing '%s' left behind by an interrupted write", filePath)
+			return os.Remove(filePath)
+		}
+		if !strings.HasSuffix(name, checksumSuffix) {
+			return nil
+		}
+
+		configFile := strings.TrimSuffix(filePath, checksumSuffix)
+		data, err := os.ReadFile(configFile)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		checksums, err := readChecksumSidecar(filePath)
+		if err != nil {
+			return err
+		}
+		sum := checksum(data)
+		if !slices.Contains(checksums, sum) {
+			corrupted = append(corrupted, configFile)
+			return nil
+		}
+		// a pending sidecar left by an interrupted write is completed with the content the file ended up with
+		if len(checksums) > 1 {
+			log.Printf("Completing the checksum of '%s' left pending by an interrupted write", configFile)
+			return writeChecksumSidecar(configFile, sum)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify the checksums of '%s': %w", dir, err)
+	}
+
+	if len(corrupted) > 0 {
+		return fmt.Errorf("files in '%s' do not match their checksums and may be corrupted: %s. Restore a backup of the "+
+			"org, or fix the files and delete their %s sidecars", dir, strings.Join(corrupted, ", "), checksumSuffix)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package mrmo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnitWriteChecksummedFile(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "source-1.tf.json")
+
+	if err := writeChecksummedFile(configFile, []byte(`{"resource":{}}`)); err != nil {
+		t.Fatal(err)
+	}
+	sidecar, err := os.ReadFile(configFile + checksumSuffix)
+	if err != nil {
+		t.Fatalf("expected a checksum sidecar: %v", err)
+	}
+	if expected := checksum([]byte(`{"resource":{}}`)) + "  source-1.tf.json\n"; string(sidecar) != expected {
+		t.Errorf("expected sidecar '%s', got '%s'", expected, sidecar)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the file and its sidecar in the directory, got %d entries", len(entries))
+	}
+	if err = verifyChecksums(dir); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUnitVerifyChecksumsDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "source-1.tf.json")
+	if err := writeChecksummedFile(configFile, []byte(`{"resource":{}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files without a sidecar, sidecars of deleted files and leftovers of interrupted writes are not corruption
+	if err := os.WriteFile(filepath.Join(dir, "legacy.tf.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "deleted.tf.json"+checksumSuffix), []byte("abc  deleted.tf.json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	leftover := filepath.Join(dir, ".source-1.tf.json.123"+tmpSuffix)
+	if err := os.WriteFile(leftover, []byte(`{"reso`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyChecksums(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fileExists(leftover) {
+		t.Error("expected the leftover temporary file to be removed")
+	}
+
+	if err := os.WriteFile(configFile, []byte(`{"reso`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := verifyChecksums(dir)
+	if err == nil || !strings.Contains(err.Error(), configFile) {
+		t.Errorf("expected an error naming '%s', got %v", configFile, err)
+	}
+}
+
+func TestUnitVerifyChecksumsAcceptsPendingSidecar(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "source-1.tf.json")
+	previous, next := []byte(`{"version":1}`), []byte(`{"version":2}`)
+
+	// a write interrupted before or after its rename leaves either content with a sidecar recording both
+	for _, content := range [][]byte{previous, next} {
+		if err := writeFile(configFile, content); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeChecksumSidecar(configFile, checksum(next), checksum(previous)); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyChecksums(dir); err != nil {
+			t.Errorf("unexpected error for %s: %v", content, err)
+		}
+		if checksums, err := readChecksumSidecar(configFile + checksumSuffix); err != nil || len(checksums) != 1 || checksums[0] != checksum(content) {
+			t.Errorf("expected the sidecar to be completed with the checksum of %s, got %v (%v)", content, checksums, err)
+		}
+	}
+
+	// a completed write leaves only the checksum of the new content
+	if err := writeChecksummedFile(configFile, previous); err != nil {
+		t.Fatal(err)
+	}
+	if checksums, err := readChecksumSidecar(configFile + checksumSuffix); err != nil || len(checksums) != 1 || checksums[0] != checksum(previous) {
+		t.Errorf("expected only the checksum of the new content, got %v (%v)", checksums, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Package config holds the settings of the credentials file that configure the executor, the state backend and the
+// config store. They are kept apart from the packages implementing them, so that org_manager can parse the credentials
+// file without depending on those packages.
+package config
+
+import "time"
+
+// Executor selects and configures the Executor implementation
+type Executor struct {
+	// Type is either "tofu" (the default), "terraform" or "inprocess"
+	Type string `yaml:"type"`
+	// BinaryPath is the path of the binary to run. Defaults to the binary of the chosen type found on PATH.
+	BinaryPath string `yaml:"binaryPath"`
+	// MinimumVersion is the oldest version of the binary that may be used
+	MinimumVersion string `yaml:"minimumVersion"`
+
+	// PluginCacheDir is the provider plugin cache shared by every target. Defaults to a directory in the user's cache
+	// directory.
+	PluginCacheDir string `yaml:"pluginCacheDir"`
+	// FilesystemMirror is a directory containing the providers in the unpacked or packed mirror layout. When set,
+	// providers are installed from the mirror only, so init does not need internet access.
+	FilesystemMirror string `yaml:"filesystemMirror"`
+
+	// Timeouts limits how long each stage of a run may take
+	Timeouts Timeouts `yaml:"timeouts"`
+}
+
+// Timeouts limits how long each stage of a run may take. Zero values fall back to the defaults.
+type Timeouts struct {
+	Init  time.Duration `yaml:"init"`
+	Plan  time.Duration `yaml:"plan"`
+	Apply time.Duration `yaml:"apply"`
+	// Read applies to commands that only read state or outputs, e.g. show and output
+	Read time.Duration `yaml:"read"`
+	// InterruptGracePeriod is how long the binary is given to exit (and release its state lock) after being
+	// interrupted, before it is killed
+	InterruptGracePeriod time.Duration `yaml:"interruptGracePeriod"`
+}
+
+// StateBackend configures where the state of each target org is stored. When Type is empty, no backend config is
+// generated and state is kept in the target's config directory.
+type StateBackend struct {
+	// Type is either "s3" or "local"
+	Type string `yaml:"type"`
+
+	// Bucket is the S3 bucket the state of every target org is stored in
+	Bucket string `yaml:"bucket"`
+	// KeyPrefix is prepended to the state key of each target org. Defaults to "organizations".
+	KeyPrefix string `yaml:"keyPrefix"`
+	Region    string `yaml:"region"`
+	// Endpoint is the URL of an S3 compatible service, e.g. a local MinIO instance
+	Endpoint string `yaml:"endpoint"`
+	// LockTable is the DynamoDB table used for state locking
+	LockTable string `yaml:"lockTable"`
+	// LockTableEndpoint is the URL of a DynamoDB compatible service hosting LockTable
+	LockTableEndpoint string `yaml:"lockTableEndpoint"`
+	// UseLockfile locks state using a lock file stored alongside it in the bucket, for services without DynamoDB
+	UseLockfile bool `yaml:"useLockfile"`
+
+	// Path is the directory the state of every target org is stored in when Type is "local". The local backend
+	// locks state using file system locks.
+	Path string `yaml:"path"`
+}
+
+// Storage selects and configures the ConfigStore implementation
+type Storage struct {
+	// Type is either "local" (the default) or "s3"
+	Type string `yaml:"type"`
+
+	// Root is the directory of the local store. Defaults to "mock-s3".
+	Root string `yaml:"root"`
+
+	// Workspace is the local directory tofu runs in when the store is not local. Target config directories are synced
+	// into it before each run. Defaults to ".mrmo/workspace".
+	Workspace string `yaml:"workspace"`
+
+	Bucket string `yaml:"bucket"`
+	// Prefix is prepended to every key in the bucket
+	Prefix string `yaml:"prefix"`
+	Region string `yaml:"region"`
+	// Endpoint is the URL of an S3 compatible service, e.g. a local MinIO instance. Defaults to AWS S3 in Region.
+	Endpoint string `yaml:"endpoint"`
+	// UsePathStyle addresses the bucket in the path rather than the host name. Always used with a custom Endpoint.
+	UsePathStyle bool `yaml:"usePathStyle"`
+	// AccessKeyId and SecretAccessKey override the default AWS credential chain (environment variables, shared
+	// profiles, SSO and instance or task roles) when set
+	AccessKeyId     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+}
@@ -0,0 +1,94 @@
+package config_store
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// TmpSuffix ends the names of the temporary files that atomic writes go through
+const TmpSuffix = ".tmp"
+
+// WriteFileAtomic writes filePath so that it holds either its previous or its new content, even if the process crashes
+// midway.
+//
+// Parameters:
+//   - filePath: The file to write
+//   - write: Writes the new content to the temporary file at tmpPath
+//   - beforeRename: If set, is called with the new content once it is synced to disk, before it replaces filePath
+//
+// Steps:
+//  1. The new content is written to a temporary file in the same directory and synced to disk
+//  2. beforeRename is called, e.g. to record the checksum of the new content
+//  3. The temporary file is renamed over filePath, and the directory is synced to persist the rename
+//
+// The temporary file is removed if any step fails.
+func WriteFileAtomic(filePath string, write func(tmpPath string) error, beforeRename func(data []byte) error) (err error) {
+	dir := filepath.Dir(filePath)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".*"+TmpSuffix)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if err = write(tmpPath); err != nil {
+		return err
+	}
+	data, err := syncFile(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+
+	if beforeRename != nil {
+		if err = beforeRename(data); err != nil {
+			return err
+		}
+	}
+
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncFile flushes the file at filePath to disk and returns its content
+func syncFile(filePath string) ([]byte, error) {
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return data, file.Sync()
+}
+
+// syncDir flushes the entries of dir to disk, persisting renames and removals in it
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err = d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
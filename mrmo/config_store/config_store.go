@@ -0,0 +1,66 @@
+package config_store
+
+import (
+	"context"
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"path/filepath"
+)
+
+// ConfigStore stores the config directories of the target orgs. Keys are slash-separated paths relative to the root of
+// the store, e.g. "organizations/<org ID>/config/provider.tf.json". Reading or deleting a key that doesn't exist
+// returns an error wrapping fs.ErrNotExist.
+type ConfigStore interface {
+	// Name describes the store in logs, e.g. "s3://bucket/prefix"
+	Name() string
+	Read(ctx context.Context, key string) ([]byte, error)
+	Write(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	// List returns the sorted keys starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+)
+
+const (
+	defaultRoot      = "mock-s3"
+	defaultWorkspace = ".mrmo/workspace"
+)
+
+// New creates the ConfigStore described by cfg
+func New(cfg config.Storage) (_ ConfigStore, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to create config store: %w", err)
+		}
+	}()
+
+	switch cfg.Type {
+	case "", TypeLocal:
+		root := cfg.Root
+		if root == "" {
+			root = defaultRoot
+		}
+		return NewLocalStore(root), nil
+	case TypeS3:
+		return newS3Store(cfg)
+	}
+	return nil, fmt.Errorf("unknown config store type '%s'. Expected '%s' or '%s'", cfg.Type, TypeLocal, TypeS3)
+}
+
+// WorkspaceDir returns the local directory holding the files under prefix, and whether it must be synced with the store.
+// A local store is its own workspace.
+func WorkspaceDir(cfg config.Storage, store ConfigStore, prefix string) (dir string, synced bool) {
+	if local, ok := store.(*LocalStore); ok {
+		return local.Path(prefix), false
+	}
+
+	workspace := cfg.Workspace
+	if workspace == "" {
+		workspace = defaultWorkspace
+	}
+	return filepath.Join(workspace, filepath.FromSlash(prefix)), true
+}
@@ -0,0 +1,88 @@
+package config_store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalStore keeps configs in a directory on the local filesystem
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+func (s *LocalStore) Name() string {
+	return s.root
+}
+
+// Path returns the path of key on the local filesystem
+func (s *LocalStore) Path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *LocalStore) Read(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.Path(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", key, err)
+	}
+	return data, nil
+}
+
+func (s *LocalStore) Write(_ context.Context, key string, data []byte) error {
+	// a crash midway never leaves a truncated file behind
+	err := WriteFileAtomic(s.Path(key), func(tmpPath string) error {
+		return os.WriteFile(tmpPath, data, 0644)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write '%s': %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	if err := os.Remove(s.Path(key)); err != nil {
+		return fmt.Errorf("failed to delete '%s': %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) List(_ context.Context, prefix string) ([]string, error) {
+	// only walk the directory the prefix is in
+	dir := prefix
+	if !strings.HasSuffix(prefix, "/") {
+		dir = path.Dir(prefix)
+	}
+
+	var keys []string
+	err := filepath.WalkDir(s.Path(dir), func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(relativePath); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list '%s': %w", prefix, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
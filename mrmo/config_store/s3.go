@@ -0,0 +1,163 @@
+package config_store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsHttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// s3Store keeps configs in a bucket of AWS S3 or an S3 compatible service
+type s3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func newS3Store(cfg config.Storage) (*s3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 config store requires a bucket")
+	}
+
+	loadOptions := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithHTTPClient(awsHttp.NewBuildableClient().WithTimeout(time.Minute)),
+	}
+	if cfg.Region != "" {
+		loadOptions = append(loadOptions, awsConfig.WithRegion(cfg.Region))
+	}
+	// Keys in the credentials file take precedence over the default credential chain, which covers the AWS_*
+	// environment variables, shared profiles, SSO and instance or task roles
+	if cfg.AccessKeyId != "" || cfg.SecretAccessKey != "" {
+		if cfg.AccessKeyId == "" || cfg.SecretAccessKey == "" {
+			return nil, fmt.Errorf("s3 config store requires both accessKeyId and secretAccessKey when either is set")
+		}
+		loadOptions = append(loadOptions, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyId, cfg.SecretAccessKey, ""),
+		))
+	}
+
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the AWS config of the s3 config store: %w", err)
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = "us-east-1"
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(options *s3.Options) {
+		options.UsePathStyle = cfg.UsePathStyle || cfg.Endpoint != ""
+		// S3 compatible services don't all support the checksums the client adds by default
+		options.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		options.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		if cfg.Endpoint != "" {
+			options.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	return &s3Store{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+	}, nil
+}
+
+func (s *s3Store) Name() string {
+	return "s3://" + path.Join(s.bucket, s.prefix)
+}
+
+func (s *s3Store) Read(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if isNotFound(err) {
+		return nil, fmt.Errorf("failed to read '%s': %w", key, fs.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", key, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", key, err)
+	}
+	return data, nil
+}
+
+func (s *s3Store) Write(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(s.objectKey(key)),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write '%s': %w", key, err)
+	}
+	return nil
+}
+
+// Delete deletes the object at key. S3 doesn't report whether the object existed, so deleting a missing key succeeds.
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete '%s': %w", key, err)
+	}
+	return nil
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.objectKey(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list '%s': %w", prefix, err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(strings.TrimPrefix(aws.ToString(object.Key), s.prefix), "/"))
+		}
+	}
+	return keys, nil
+}
+
+// objectKey returns the key of the object in the bucket, with the store's prefix
+func (s *s3Store) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	if key == "" {
+		return s.prefix + "/"
+	}
+	return s.prefix + "/" + key
+}
+
+// isNotFound returns true if err reports that the object does not exist
+func isNotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var responseErr *awsHttp.ResponseError
+	return errors.As(err, &responseErr) && responseErr.HTTPStatusCode() == http.StatusNotFound
+}
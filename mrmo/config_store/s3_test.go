@@ -0,0 +1,181 @@
+package config_store
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3 compatible service, addressed in path style. Listings return one
+// key per page, to exercise pagination.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>"))
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		start = sort.SearchStrings(keys, token)
+	}
+
+	var result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []struct{ Key string }
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}
+	if start < len(keys) {
+		result.Contents = append(result.Contents, struct{ Key string }{keys[start]})
+	}
+	if start+1 < len(keys) {
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[start+1]
+	}
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func TestUnitS3Store(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(&fakeS3{bucket: "mrmo", objects: make(map[string][]byte)})
+	defer server.Close()
+
+	store, err := New(config.Storage{
+		Type:            TypeS3,
+		Bucket:          "mrmo",
+		Prefix:          "configs",
+		Endpoint:        server.URL,
+		AccessKeyId:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"organizations/org-a/config/provider.tf.json":        `{"provider":{}}`,
+		"organizations/org-a/config/prompts/hello world.wav": "RIFF",
+		"organizations/org-b/config/provider.tf.json":        `{}`,
+	}
+	for key, content := range files {
+		if err = store.Write(ctx, key, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := store.Read(ctx, "organizations/org-a/config/prompts/hello world.wav")
+	if err != nil || string(data) != "RIFF" {
+		t.Fatalf("expected to read back the object, got '%s' (%v)", string(data), err)
+	}
+
+	keys, err := store.List(ctx, "organizations/org-a/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"organizations/org-a/config/prompts/hello world.wav", "organizations/org-a/config/provider.tf.json"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+
+	if err = store.Delete(ctx, "organizations/org-a/config/provider.tf.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Read(ctx, "organizations/org-a/config/provider.tf.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not exist error after delete, got %v", err)
+	}
+}
+
+func TestUnitS3StoreCredentials(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// keep the shared config of the machine out of the default credential chain
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+
+	tests := []struct {
+		name     string
+		storage  config.Storage
+		expected string
+	}{
+		{"default credential chain", config.Storage{Bucket: "mrmo"}, "env-key"},
+		{"keys in the credentials file", config.Storage{Bucket: "mrmo", AccessKeyId: "test-key", SecretAccessKey: "test-secret"}, "test-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := newS3Store(tt.storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			credentials, err := store.client.Options().Credentials.Retrieve(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credentials.AccessKeyID != tt.expected {
+				t.Errorf("expected access key '%s', got '%s'", tt.expected, credentials.AccessKeyID)
+			}
+		})
+	}
+
+	if _, err := newS3Store(config.Storage{Bucket: "mrmo", AccessKeyId: "test-key"}); err == nil {
+		t.Error("expected an error for an access key without a secret")
+	}
+}
@@ -0,0 +1,183 @@
+package mrmo
+
+import (
+	"context"
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider"
+	resourceExporter "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"log"
+	"sort"
+)
+
+// isAltValue returns true if value is one of the RefAttr's alternative values (e.g. "*" for all divisions), which are
+// valid in every org and must not be resolved as GUIDs
+func isAltValue(settings *resourceExporter.RefAttrSettings, value string) bool {
+	if settings == nil {
+		return false
+	}
+	for _, altValue := range settings.AltValues {
+		if altValue == value {
+			return true
+		}
+	}
+	return false
+}
+
+// extractJsonEncodedReferences finds the whole GUIDs inside the exporter's JSON encoded attributes (see findWholeGuids).
+// The referenced resource type of these GUIDs is unknown, so they can only be resolved using the mapping table.
+func (m *MrMo) extractJsonEncodedReferences(individualResourceConfig util.JsonMap) (references []reference) {
+	if m.Exporter == nil {
+		return
+	}
+
+	for _, attribute := range m.Exporter.JsonEncodeAttributes {
+		for _, value := range findValuesAtPath(individualResourceConfig, attribute) {
+			encoded, ok := value.Value.(string)
+			if !ok {
+				continue
+			}
+			for _, guid := range uniqueStrings(findWholeGuids(encoded)) {
+				references = append(references, reference{
+					Path:     attribute,
+					Location: value.Location,
+					Guid:     guid,
+				})
+			}
+		}
+	}
+	return
+}
+
+// applyCustomAttributeResolvers invokes the exporter's custom resolver functions on the exported config of the resource,
+// as the exporter does during an export, so that attributes the exporter encodes in its own way are rewritten before
+// references are collected. Only the exporter of the resource type is passed to the resolvers, since the config of
+// the referenced resources is not exported along with it.
+func (m *MrMo) applyCustomAttributeResolvers(exportedConfig util.JsonMap) error {
+	if m.Exporter == nil || len(m.Exporter.CustomAttributeResolver) == 0 {
+		return nil
+	}
+
+	individualResourceConfig := m.getIndividualResourceConfig(exportedConfig)
+	if individualResourceConfig == nil {
+		return fmt.Errorf("no config found for '%s' to apply custom resolvers to", m.ResourcePath)
+	}
+	exporters := map[string]*resourceExporter.ResourceExporter{m.ResourceType: m.Exporter}
+
+	// apply the resolvers in a fixed order, in case more than one rewrites the same attribute
+	var paths []string
+	for path := range m.Exporter.CustomAttributeResolver {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		resolver := m.Exporter.CustomAttributeResolver[path]
+		if resolver == nil || resolver.ResolverFunc == nil {
+			continue
+		}
+		if err := resolver.ResolverFunc(individualResourceConfig, exporters, m.ResourceLabel); err != nil {
+			return fmt.Errorf("custom resolver for '%s' of '%s' failed: %w", path, m.ResourcePath, err)
+		}
+	}
+	return nil
+}
+
+// resolveReferenceToName checks that the target org has exactly one entity of the reference's RefType with the name the
+// reference holds. Names are the same in every org, so the reference itself does not need to be replaced. Target
+// entities sharing the name are told apart by the label of the referenced entity in the source org.
+func (m *MrMo) resolveReferenceToName(ctx context.Context, ref reference, target orgManager.OrgData) error {
+	label := m.sourceEntityLabelByName(ctx, ref.RefType, ref.Name)
+	targetGuid, err := m.findEntityIdByName(ctx, ref.RefType, target, ref.Name, label)
+	if err != nil {
+		return fmt.Errorf("failed to find %s named '%s' in org '%s': %w", ref.RefType, ref.Name, target.OrgId, err)
+	}
+	log.Printf("Found %s '%s' referenced by name at '%s' in org '%s' ('%s')", ref.RefType, ref.Name, ref.Location, target.OrgId, targetGuid)
+	return nil
+}
+
+// sourceEntityLabelByName returns the label the exporter gives the only entity of resourceType named name in the source
+// org, or an empty string if there is no such entity
+func (m *MrMo) sourceEntityLabelByName(ctx context.Context, resourceType, name string) string {
+	sourceId, err := m.findEntityIdByName(ctx, resourceType, m.OrgManager.Source, name, "")
+	if err != nil {
+		log.Printf("Label of %s '%s' not known from the source org: %s", resourceType, name, err.Error())
+		return ""
+	}
+	entities, err := m.getEntities(ctx, resourceType, m.OrgManager.Source)
+	if err != nil || entities[sourceId] == nil {
+		return ""
+	}
+	return entities[sourceId].BlockLabel
+}
+
+// resolveReferenceWithDataSource invokes the exporter's custom resolver for the reference's attribute, if it has one
+// that resolves the reference to a data source (e.g. flows referenced by name). The data source's name is then used to
+// find the equivalent entity in the target org, and the discovered mapping is recorded.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - ref: The unresolved reference
+//   - individualResourceConfig: The exported config of the resource holding the reference
+//   - target: The target org data
+//
+// Returns:
+//   - string: The ID of the matching entity in the target org
+//   - error: Error if the attribute has no data source resolver, or no unique matching entity exists in the target org
+func (m *MrMo) resolveReferenceWithDataSource(ctx context.Context, ref reference, individualResourceConfig util.JsonMap, target orgManager.OrgData) (_ string, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to resolve '%s' using the exporter's custom resolver: %w", ref.Location, err)
+		}
+	}()
+
+	resolver := m.getDataSourceResolver(ref.Path)
+	if resolver == nil {
+		return "", fmt.Errorf("no data source resolver defined for '%s'", ref.Path)
+	}
+
+	sourceProviderMeta, ok := m.ProviderMeta.(*provider.ProviderMeta)
+	if !ok {
+		return "", fmt.Errorf("expected provider meta of type *provider.ProviderMeta, got %T", m.ProviderMeta)
+	}
+
+	dataSourceType, dataSourceLabel, dataSourceConfig, resolve := resolver.ResolveToDataSourceFunc(individualResourceConfig, ref.Guid, sourceProviderMeta.ClientConfig)
+	if !resolve {
+		return "", fmt.Errorf("resolver declined to resolve '%s'", ref.Guid)
+	}
+
+	name, _ := dataSourceConfig["name"].(string)
+	if name == "" {
+		return "", fmt.Errorf("data source '%s' for '%s' has no name", dataSourceType, ref.Guid)
+	}
+
+	// the data source is labelled like the entity, which tells apart target entities sharing the name
+	targetGuid, err := m.findEntityIdByName(ctx, dataSourceType, target, name, dataSourceLabel)
+	if err != nil {
+		return "", err
+	}
+	if err = ensureTargetNotMappedElsewhere(dataSourceType, targetGuid, target.OrgId, ref.Guid); err != nil {
+		return "", err
+	}
+
+	log.Printf("Resolved %s '%s' to '%s' in org '%s' using data source '%s'", dataSourceType, ref.Guid, targetGuid, target.OrgId, name)
+	if err = mockDynamo.UpdateItem(dataSourceType, ref.Guid, target.OrgId, targetGuid); err != nil {
+		return "", err
+	}
+	return targetGuid, nil
+}
+
+// getDataSourceResolver returns the exporter's custom resolver for the attribute path, if one is defined that resolves
+// references to a data source
+func (m *MrMo) getDataSourceResolver(path string) *resourceExporter.RefAttrCustomResolver {
+	if m.Exporter == nil || m.Exporter.CustomAttributeResolver == nil {
+		return nil
+	}
+	resolver := m.Exporter.CustomAttributeResolver[path]
+	if resolver == nil || resolver.ResolveToDataSourceFunc == nil {
+		return nil
+	}
+	return resolver
+}
@@ -0,0 +1,208 @@
+package mrmo
+
+import (
+	"context"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mypurecloud/platform-client-sdk-go/v154/platformclientv2"
+	resourceExporter "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/tfexporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"strings"
+	"testing"
+)
+
+func resolverTestConfig(resourceConfig util.JsonMap) util.JsonMap {
+	return util.JsonMap{
+		"resource": map[string]tfexporter.ResourceJSONMaps{
+			testResourceType: {testResourceLabel: resourceConfig},
+		},
+	}
+}
+
+func resolvedTestValue(t *testing.T, m *MrMo, config util.JsonMap, path string) any {
+	t.Helper()
+	values := getValuesAtPath(m.getIndividualResourceConfig(config), path)
+	if len(values) != 1 {
+		t.Fatalf("expected one value at '%s', got %v", path, values)
+	}
+	return values[0]
+}
+
+func TestUnitResolveJsonEncodedReferences(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a"}
+	mappedGuid := uuid.NewString()
+	unmappedGuid := uuid.NewString()
+	mockDynamo.UseTestTable(t, mappingItem("genesyscloud_routing_skill", mappedGuid, target.OrgId, "target-skill"))
+
+	m := newTestMrMo(t)
+	m.Exporter = &resourceExporter.ResourceExporter{JsonEncodeAttributes: []string{"settings"}}
+	// the type of a JSON encoded GUID is unknown, so it is only warned about, even in strict mode
+	m.StrictMode = true
+	// a GUID that is part of a longer token is not a reference
+	settings := `{"skillId":"` + mappedGuid + `","other":"` + unmappedGuid + `","key":"skill-` + mappedGuid + `"}`
+	config := resolverTestConfig(util.JsonMap{"settings": settings})
+
+	resolved, diags := m.resolveResourceConfigDependencies(context.Background(), config, target)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || !strings.Contains(diags[0].Detail, unmappedGuid) {
+		t.Errorf("expected a warning for the unmapped GUID only, got %v", diags)
+	}
+	expected := `{"skillId":"target-skill","other":"` + unmappedGuid + `","key":"skill-` + mappedGuid + `"}`
+	if value := resolvedTestValue(t, m, resolved, "settings"); value != expected {
+		t.Errorf("expected settings '%s', got '%v'", expected, value)
+	}
+	if value := resolvedTestValue(t, m, config, "settings"); value != settings {
+		t.Errorf("expected the exported config to be left unchanged, got '%v'", value)
+	}
+}
+
+func TestUnitApplyCustomAttributeResolvers(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a"}
+	sourceGuid := uuid.NewString()
+	mockDynamo.UseTestTable(t, mappingItem("genesyscloud_script", sourceGuid, target.OrgId, "target-script"))
+
+	// the resolver moves the script ID out of the attribute the exporter encodes it in
+	var calledWith string
+	exporter := &resourceExporter.ResourceExporter{
+		RefAttrs: map[string]*resourceExporter.RefAttrSettings{
+			"script_id": {RefType: "genesyscloud_script"},
+		},
+		CustomAttributeResolver: map[string]*resourceExporter.RefAttrCustomResolver{
+			"script_id": {
+				ResolverFunc: func(config map[string]interface{}, exporters map[string]*resourceExporter.ResourceExporter, label string) error {
+					calledWith = label
+					if exporters[testResourceType] == nil {
+						t.Error("expected the exporter of the resource type to be passed to the resolver")
+					}
+					config["script_id"] = strings.TrimPrefix(config["script"].(string), "script:")
+					delete(config, "script")
+					return nil
+				},
+			},
+		},
+	}
+	m := newTestMrMo(t)
+	m.Exporter = exporter
+	config := resolverTestConfig(util.JsonMap{"script": "script:" + sourceGuid})
+
+	if err := m.applyCustomAttributeResolvers(config); err != nil {
+		t.Fatal(err)
+	}
+	if calledWith != testResourceLabel {
+		t.Errorf("expected the resolver to be called with label '%s', got '%s'", testResourceLabel, calledWith)
+	}
+
+	resolved, diags := m.resolveResourceConfigDependencies(context.Background(), config, target)
+	if len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+	if value := resolvedTestValue(t, m, resolved, "script_id"); value != "target-script" {
+		t.Errorf("expected script_id 'target-script', got '%v'", value)
+	}
+}
+
+func TestUnitResolveReferenceWithDataSource(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a"}
+	sourceGuid := uuid.NewString()
+	mockDynamo.UseTestTable(t)
+
+	exporter := &resourceExporter.ResourceExporter{
+		RefAttrs: map[string]*resourceExporter.RefAttrSettings{
+			"flow_id": {RefType: "genesyscloud_flow"},
+		},
+		CustomAttributeResolver: map[string]*resourceExporter.RefAttrCustomResolver{
+			"flow_id": {
+				ResolveToDataSourceFunc: func(config map[string]interface{}, id any, _ *platformclientv2.Configuration) (string, string, map[string]interface{}, bool) {
+					return "genesyscloud_flow", "Inbound", map[string]interface{}{"name": "Inbound"}, id == sourceGuid
+				},
+			},
+		},
+	}
+	m := newTestMrMo(t)
+	m.Exporter = exporter
+	withCachedEntities(m, target.OrgId, "genesyscloud_flow", map[string]string{"target-flow": "Inbound", "twin-flow": "Inbound", "other-flow": "Outbound"})
+	// the exporter relabels the second entity with the name, which tells the two apart
+	m.entityCache[target.OrgId]["genesyscloud_flow"]["twin-flow"].BlockLabel = "Inbound_2"
+
+	resolved, diags := m.resolveResourceConfigDependencies(context.Background(), resolverTestConfig(util.JsonMap{"flow_id": sourceGuid}), target)
+	if len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+	if value := resolvedTestValue(t, m, resolved, "flow_id"); value != "target-flow" {
+		t.Errorf("expected flow_id 'target-flow', got '%v'", value)
+	}
+	if mapped, err := mockDynamo.GetTargetIdBySourceId(sourceGuid, target.OrgId); err != nil || mapped != "target-flow" {
+		t.Errorf("expected the mapping to target-flow to be recorded, got '%s' (%v)", mapped, err)
+	}
+}
+
+func TestUnitResolveReferencesByName(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a"}
+	mockDynamo.UseTestTable(t)
+
+	exporter := &resourceExporter.ResourceExporter{
+		RefAttrs: map[string]*resourceExporter.RefAttrSettings{
+			"flow_name":    {RefType: "genesyscloud_flow"},
+			"interpolated": {RefType: "genesyscloud_flow"},
+		},
+	}
+	newMrMo := func(strict bool) *MrMo {
+		m := newTestMrMo(t)
+		m.Exporter = exporter
+		withCachedEntities(m, target.OrgId, "genesyscloud_flow", map[string]string{"target-flow": "Inbound"})
+		m.StrictMode = strict
+		m.NameMatching = true
+		return m
+	}
+	interpolation := "${genesyscloud_flow.inbound.id}"
+
+	t.Run("keeps a name found in the target org", func(t *testing.T) {
+		m := newMrMo(true)
+		resolved, diags := m.resolveResourceConfigDependencies(context.Background(), resolverTestConfig(util.JsonMap{"flow_name": "Inbound", "interpolated": interpolation}), target)
+		if len(diags) != 0 {
+			t.Errorf("unexpected diagnostics: %v", diags)
+		}
+		if value := resolvedTestValue(t, m, resolved, "flow_name"); value != "Inbound" {
+			t.Errorf("expected the name to be kept, got '%v'", value)
+		}
+	})
+
+	t.Run("reports a name missing from the target org", func(t *testing.T) {
+		for _, strict := range []bool{false, true} {
+			_, diags := newMrMo(strict).resolveResourceConfigDependencies(context.Background(), resolverTestConfig(util.JsonMap{"flow_name": "Outbound"}), target)
+			if len(diags) != 1 || diags.HasError() != strict || !strings.Contains(diags[0].Detail, "'Outbound'") {
+				t.Errorf("expected one diagnostic for 'Outbound' (error: %t), got %v", strict, diags)
+			}
+		}
+	})
+
+	t.Run("keeps a name without looking it up when name matching is off", func(t *testing.T) {
+		m := newTestMrMo(t)
+		m.Exporter = exporter
+		m.StrictMode = true
+		resolved, diags := m.resolveResourceConfigDependencies(context.Background(), resolverTestConfig(util.JsonMap{"flow_name": "Outbound"}), target)
+		if len(diags) != 0 {
+			t.Errorf("unexpected diagnostics: %v", diags)
+		}
+		if value := resolvedTestValue(t, m, resolved, "flow_name"); value != "Outbound" {
+			t.Errorf("expected the name to be kept, got '%v'", value)
+		}
+	})
+}
+
+func TestUnitIsGuid(t *testing.T) {
+	guid := uuid.NewString()
+	for value, expected := range map[string]bool{
+		guid:                  true,
+		strings.ToUpper(guid): true,
+		"prefix-" + guid:      false,
+		guid + "/suffix":      false,
+		"*":                   false,
+	} {
+		if isGuid(value) != expected {
+			t.Errorf("expected isGuid('%s') to be %t", value, expected)
+		}
+	}
+}
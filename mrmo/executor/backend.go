@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"path"
+)
+
+const (
+	BackendTypeLocal = "local"
+	BackendTypeS3    = "s3"
+)
+
+// RenderStateBackend returns the terraform block configuring the backend b for the given org, or nil if no backend is
+// configured
+func RenderStateBackend(b config.StateBackend, orgId string) (map[string]any, error) {
+	var backend map[string]any
+	switch b.Type {
+	case "":
+		return nil, nil
+	case BackendTypeS3:
+		s3Backend, err := renderS3Backend(b, orgId)
+		if err != nil {
+			return nil, err
+		}
+		backend = map[string]any{BackendTypeS3: s3Backend}
+	case BackendTypeLocal:
+		if b.Path == "" {
+			return nil, fmt.Errorf("local state backend requires a path")
+		}
+		backend = map[string]any{
+			BackendTypeLocal: map[string]any{"path": path.Join(b.Path, orgId, "terraform.tfstate")},
+		}
+	default:
+		return nil, fmt.Errorf("unknown state backend type '%s'. Expected '%s' or '%s'", b.Type, BackendTypeS3, BackendTypeLocal)
+	}
+
+	return map[string]any{
+		"terraform": map[string]any{"backend": backend},
+	}, nil
+}
+
+func renderS3Backend(b config.StateBackend, orgId string) (map[string]any, error) {
+	if b.Bucket == "" {
+		return nil, fmt.Errorf("s3 state backend requires a bucket")
+	}
+	if b.LockTable == "" && !b.UseLockfile {
+		return nil, fmt.Errorf("s3 state backend requires either a lockTable or useLockfile, so that state is locked")
+	}
+
+	keyPrefix := b.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = "organizations"
+	}
+	region := b.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	config := map[string]any{
+		"bucket":  b.Bucket,
+		"key":     path.Join(keyPrefix, orgId, "terraform.tfstate"),
+		"region":  region,
+		"encrypt": true,
+	}
+	if b.LockTable != "" {
+		config["dynamodb_table"] = b.LockTable
+	}
+	if b.UseLockfile {
+		config["use_lockfile"] = true
+	}
+
+	endpoints := make(map[string]any)
+	if b.Endpoint != "" {
+		endpoints["s3"] = b.Endpoint
+	}
+	if b.LockTableEndpoint != "" {
+		endpoints["dynamodb"] = b.LockTableEndpoint
+	}
+	if len(endpoints) > 0 {
+		// S3 compatible stand-ins don't implement the AWS account and region APIs
+		config["endpoints"] = endpoints
+		config["use_path_style"] = true
+		config["skip_credentials_validation"] = true
+		config["skip_region_validation"] = true
+		config["skip_requesting_account_id"] = true
+		config["skip_metadata_api_check"] = true
+	}
+	return config, nil
+}
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"reflect"
+	"testing"
+)
+
+func TestUnitRenderStateBackend(t *testing.T) {
+	tests := []struct {
+		name        string
+		backend     config.StateBackend
+		expected    map[string]any
+		expectError bool
+	}{
+		{name: "none", backend: config.StateBackend{}},
+		{
+			name:    "local",
+			backend: config.StateBackend{Type: BackendTypeLocal, Path: "/var/lib/mrmo/state"},
+			expected: map[string]any{BackendTypeLocal: map[string]any{
+				"path": "/var/lib/mrmo/state/org-a/terraform.tfstate",
+			}},
+		},
+		{
+			name:    "s3 with a lock table",
+			backend: config.StateBackend{Type: BackendTypeS3, Bucket: "states", Region: "eu-west-1", LockTable: "locks"},
+			expected: map[string]any{BackendTypeS3: map[string]any{
+				"bucket":         "states",
+				"key":            "organizations/org-a/terraform.tfstate",
+				"region":         "eu-west-1",
+				"encrypt":        true,
+				"dynamodb_table": "locks",
+			}},
+		},
+		{
+			name:    "s3 compatible service with a lock file",
+			backend: config.StateBackend{Type: BackendTypeS3, Bucket: "states", KeyPrefix: "mrmo", Endpoint: "http://localhost:9000", UseLockfile: true},
+			expected: map[string]any{BackendTypeS3: map[string]any{
+				"bucket":                      "states",
+				"key":                         "mrmo/org-a/terraform.tfstate",
+				"region":                      "us-east-1",
+				"encrypt":                     true,
+				"use_lockfile":                true,
+				"endpoints":                   map[string]any{"s3": "http://localhost:9000"},
+				"use_path_style":              true,
+				"skip_credentials_validation": true,
+				"skip_region_validation":      true,
+				"skip_requesting_account_id":  true,
+				"skip_metadata_api_check":     true,
+			}},
+		},
+		{name: "s3 without locking", backend: config.StateBackend{Type: BackendTypeS3, Bucket: "states"}, expectError: true},
+		{name: "s3 without a bucket", backend: config.StateBackend{Type: BackendTypeS3, UseLockfile: true}, expectError: true},
+		{name: "local without a path", backend: config.StateBackend{Type: BackendTypeLocal}, expectError: true},
+		{name: "unknown type", backend: config.StateBackend{Type: "gcs"}, expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, err := RenderStateBackend(test.backend, "org-a")
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got %v", rendered)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expected map[string]any
+			if test.expected != nil {
+				expected = map[string]any{"terraform": map[string]any{"backend": test.expected}}
+			}
+			if !reflect.DeepEqual(rendered, expected) {
+				t.Errorf("expected %v, got %v", expected, rendered)
+			}
+		})
+	}
+}
+
+func TestUnitMinimumVersionWithLockfile(t *testing.T) {
+	lockfileBackend := config.StateBackend{Type: BackendTypeS3, Bucket: "states", UseLockfile: true}
+	tests := []struct {
+		name     string
+		cfg      config.Executor
+		backend  config.StateBackend
+		expected string
+	}{
+		{name: "default", expected: "1.6.0"},
+		{name: "lock table", backend: config.StateBackend{Type: BackendTypeS3, LockTable: "locks"}, expected: "1.6.0"},
+		{name: "lock file", backend: lockfileBackend, expected: "1.10.0"},
+		{name: "configured older than lock file", cfg: config.Executor{MinimumVersion: "1.7.0"}, backend: lockfileBackend, expected: "1.10.0"},
+		{name: "configured newer than lock file", cfg: config.Executor{MinimumVersion: "1.11.1"}, backend: lockfileBackend, expected: "1.11.1"},
+	}
+	for _, test := range tests {
+		if minimum := minimumVersion(TypeOpenTofu, test.cfg, test.backend); minimum != test.expected {
+			t.Errorf("%s: expected minimum version %s, got %s", test.name, test.expected, minimum)
+		}
+	}
+	if minimum := minimumVersion(TypeTerraform, config.Executor{}, lockfileBackend); minimum != "1.11.0" {
+		t.Errorf("expected minimum terraform version 1.11.0 with a lock file, got %s", minimum)
+	}
+}
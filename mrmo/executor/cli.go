@@ -0,0 +1,380 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// cliExecutor runs the OpenTofu or Terraform CLI. Both share the same commands and JSON output formats.
+type cliExecutor struct {
+	name       string
+	binaryPath string
+
+	// providerInstallation, if set, is written as the CLI config of every run
+	providerInstallation *providerInstallation
+	timeouts             stageTimeouts
+}
+
+// NewOpenTofu returns an Executor running the tofu binary at binaryPath, or the one found on PATH if binaryPath is empty
+func NewOpenTofu(binaryPath string) (Executor, error) {
+	return newCliExecutor(TypeOpenTofu, binaryPath)
+}
+
+// NewTerraform returns an Executor running the terraform binary at binaryPath, or the one found on PATH if binaryPath
+// is empty
+func NewTerraform(binaryPath string) (Executor, error) {
+	return newCliExecutor(TypeTerraform, binaryPath)
+}
+
+func newCliExecutor(name, binaryPath string) (*cliExecutor, error) {
+	if binaryPath == "" {
+		binaryPath = name
+	}
+	resolvedPath, err := exec.LookPath(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s binary '%s': %w", name, binaryPath, err)
+	}
+	return &cliExecutor{name: name, binaryPath: resolvedPath, timeouts: defaultTimeouts}, nil
+}
+
+func (c *cliExecutor) Name() string {
+	return c.name
+}
+
+func (c *cliExecutor) Version(ctx context.Context) (string, error) {
+	output, err := c.runWithOutput(ctx, "", "version", "-json")
+	if err != nil {
+		return "", err
+	}
+
+	// tofu reports its version under the same key as terraform
+	var versionOutput struct {
+		Version string `json:"terraform_version"`
+	}
+	if err = json.Unmarshal(output, &versionOutput); err != nil {
+		return "", fmt.Errorf("failed to parse %s version output: %w", c.name, err)
+	}
+	return versionOutput.Version, nil
+}
+
+// Init initializes dir, unless it was already initialized and none of the provider requirements, the lock file or the
+// provider installation config have changed since. With a filesystem mirror, the lock file is filled from the mirror
+// first.
+func (c *cliExecutor) Init(ctx context.Context, dir string) (diags diag.Diagnostics) {
+	if !c.needsInit(dir) {
+		log.Printf("Skipping %s init in '%s'. Already initialized", c.name, dir)
+		return nil
+	}
+
+	diags = append(diags, c.lockProviders(ctx, dir)...)
+	if diags.HasError() {
+		return diags
+	}
+	diags = append(diags, c.run(ctx, dir, "init", "-input=false")...)
+	if diags.HasError() {
+		return diags
+	}
+
+	if err := c.recordInit(dir); err != nil {
+		log.Printf("Failed to record init state for '%s'. Error: %s", dir, err.Error())
+	}
+	return diags
+}
+
+func (c *cliExecutor) MigrateState(ctx context.Context, dir string) (diags diag.Diagnostics) {
+	diags = append(diags, c.lockProviders(ctx, dir)...)
+	if diags.HasError() {
+		return diags
+	}
+	diags = append(diags, c.run(ctx, dir, "init", "-input=false", "-migrate-state", "-force-copy")...)
+	if diags.HasError() {
+		return diags
+	}
+
+	if err := c.recordInit(dir); err != nil {
+		log.Printf("Failed to record init state for '%s'. Error: %s", dir, err.Error())
+	}
+	return diags
+}
+
+// lockProviders records the checksums of the providers in the filesystem mirror in the lock file of dir, if providers
+// are installed from one
+func (c *cliExecutor) lockProviders(ctx context.Context, dir string) diag.Diagnostics {
+	if c.providerInstallation == nil {
+		return nil
+	}
+	if args := c.providerInstallation.lockArgs(); args != nil {
+		return c.run(ctx, dir, args...)
+	}
+	return nil
+}
+
+func (c *cliExecutor) Plan(ctx context.Context, dir string, opts PlanOptions) (_ *Plan, diags diag.Diagnostics) {
+	args := []string{"plan", "-input=false", "-out=" + opts.PlanFile}
+	if opts.Destroy {
+		args = append(args, "-destroy")
+	}
+	for _, target := range opts.Targets {
+		args = append(args, "-target", target)
+	}
+
+	diags = append(diags, c.run(ctx, dir, args...)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	output, err := c.runWithOutput(ctx, dir, "show", "-json", opts.PlanFile)
+	if err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+
+	var planOutput struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err = json.Unmarshal(output, &planOutput); err != nil {
+		return nil, append(diags, diag.Errorf("failed to parse plan '%s': %s", opts.PlanFile, err.Error())...)
+	}
+
+	plan := Plan{File: opts.PlanFile}
+	for _, change := range planOutput.ResourceChanges {
+		plan.ResourceChanges = append(plan.ResourceChanges, ResourceChange{
+			Address: change.Address,
+			Actions: change.Change.Actions,
+		})
+	}
+	return &plan, diags
+}
+
+func (c *cliExecutor) Apply(ctx context.Context, dir string, opts ApplyOptions) diag.Diagnostics {
+	args := []string{"apply", "-input=false", "-auto-approve"}
+	if opts.PlanFile != "" {
+		return c.run(ctx, dir, append(args, opts.PlanFile)...)
+	}
+	for _, target := range opts.Targets {
+		args = append(args, "-target", target)
+	}
+	return c.run(ctx, dir, args...)
+}
+
+func (c *cliExecutor) Output(ctx context.Context, dir string) (map[string]any, error) {
+	output, err := c.runWithOutput(ctx, dir, "output", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs map[string]struct {
+		Value any `json:"value"`
+	}
+	if err = json.Unmarshal(output, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s outputs: %w", c.name, err)
+	}
+
+	values := make(map[string]any)
+	for k, v := range outputs {
+		values[k] = v.Value
+	}
+	return values, nil
+}
+
+func (c *cliExecutor) Import(ctx context.Context, dir, address, id string) diag.Diagnostics {
+	return c.run(ctx, dir, "import", "-input=false", address, id)
+}
+
+func (c *cliExecutor) State(ctx context.Context, dir string) ([]StateResource, error) {
+	output, err := c.runWithOutput(ctx, dir, "show", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	var stateOutput struct {
+		Values struct {
+			RootModule struct {
+				Resources []StateResource `json:"resources"`
+			} `json:"root_module"`
+		} `json:"values"`
+	}
+	if err = json.Unmarshal(output, &stateOutput); err != nil {
+		return nil, fmt.Errorf("failed to parse %s state: %w", c.name, err)
+	}
+	return stateOutput.Values.RootModule.Resources, nil
+}
+
+func (c *cliExecutor) PullState(ctx context.Context, dir string) ([]byte, error) {
+	output, err := c.runWithOutput(ctx, dir, "state", "pull")
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+	return output, nil
+}
+
+// pushStateFile is the path, relative to the config directory, the state is written to before being pushed
+const pushStateFile = ".mrmo/push.tfstate"
+
+func (c *cliExecutor) PushState(ctx context.Context, dir string, state []byte) diag.Diagnostics {
+	statePath := filepath.Join(dir, pushStateFile)
+	if err := os.MkdirAll(filepath.Dir(statePath), os.ModePerm); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := os.WriteFile(statePath, state, 0600); err != nil {
+		return diag.Errorf("failed to write file '%s'. Error: %s", statePath, err.Error())
+	}
+	defer func() {
+		if err := os.Remove(statePath); err != nil {
+			log.Printf("Failed to remove '%s'. Error: %s", statePath, err.Error())
+		}
+	}()
+
+	// -force allows pushing a state with an older serial, which is the point of restoring one
+	return c.run(ctx, dir, "state", "push", "-force", pushStateFile)
+}
+
+func (c *cliExecutor) MoveState(ctx context.Context, dir, from, to string) diag.Diagnostics {
+	return c.run(ctx, dir, "state", "mv", from, to)
+}
+
+// run executes the binary in dir. Commands that support it are run with -json, so that the diagnostics they report
+// (with the address of the affected resource) are returned as diag.Diagnostics. The raw output of every run is kept
+// in the config directory's log directory for later inspection. The run is interrupted when ctx is done or when it
+// exceeds the timeout of its stage.
+func (c *cliExecutor) run(ctx context.Context, dir string, args ...string) (diags diag.Diagnostics) {
+	command := args[0]
+	if command == "plan" || command == "apply" {
+		// flags must come before positional arguments such as a saved plan file
+		args = append([]string{command, "-json"}, args[1:]...)
+	}
+
+	logFile, err := openRunLog(dir, command)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer func() {
+		if closeErr := logFile.Close(); closeErr != nil {
+			log.Printf("Failed to close log file '%s'. Error: %s", logFile.Name(), closeErr.Error())
+		}
+	}()
+
+	stdout := &uiWriter{raw: logFile, echo: os.Stdout}
+	stderr := &uiWriter{raw: logFile, echo: os.Stderr}
+
+	timeout := c.timeouts.forCommand(command)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd, err := c.command(ctx, dir, args...)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	runErr := cmd.Run()
+	stdout.flush()
+	stderr.flush()
+
+	diags = append(diags, stdout.diags...)
+	diags = append(diags, stderr.diags...)
+
+	if runErr != nil {
+		if ctxDiags := c.contextDiagnostic(ctx, command, dir, timeout, logFile.Name()); ctxDiags != nil {
+			return append(diags, ctxDiags...)
+		}
+	}
+
+	if runErr != nil && !diags.HasError() {
+		// the command failed without reporting why, e.g. because it does not support -json
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("%s %s failed in '%s': %s", c.name, command, dir, runErr.Error()),
+			Detail:   fmt.Sprintf("See '%s' for the full output.", logFile.Name()),
+		})
+	}
+	return diags
+}
+
+// runWithOutput executes the binary in dir and returns its stdout
+func (c *cliExecutor) runWithOutput(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var outputBuffer bytes.Buffer
+
+	timeout := c.timeouts.forCommand(args[0])
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd, err := c.command(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = &outputBuffer
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxDiags := c.contextDiagnostic(ctx, args[0], dir, timeout, ""); ctxDiags != nil {
+			return nil, errors.New(ctxDiags[0].Summary)
+		}
+		return nil, fmt.Errorf("%s %s failed in '%s': %w", c.name, args[0], dir, err)
+	}
+	return outputBuffer.Bytes(), nil
+}
+
+// command builds the command running the binary in dir, pointing it at the plugin cache and, when providers are
+// installed from a filesystem mirror, at a CLI config merging the user's own. The command is interrupted when ctx is
+// done.
+func (c *cliExecutor) command(ctx context.Context, dir string, args ...string) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, c.binaryPath, args...)
+	cmd.Dir = dir
+	interruptOnCancel(cmd, c.timeouts.InterruptGracePeriod)
+
+	if c.providerInstallation != nil && dir != "" {
+		env, err := c.providerInstallation.env(dir)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd, nil
+}
+
+// checkMinimumVersion returns an error if the executor's binary is older than minimumVersion
+func checkMinimumVersion(ctx context.Context, executor Executor, minimumVersion string) error {
+	if minimumVersion == "" {
+		return nil
+	}
+
+	currentVersion, err := executor.Version(ctx)
+	if err != nil {
+		return err
+	}
+	return compareVersions(executor.Name(), currentVersion, minimumVersion)
+}
+
+func compareVersions(name, currentVersion, minimumVersion string) error {
+	current, err := version.NewVersion(currentVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s version '%s': %w", name, currentVersion, err)
+	}
+	minimum, err := version.NewVersion(minimumVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse minimum %s version '%s': %w", name, minimumVersion, err)
+	}
+
+	if current.LessThan(minimum) {
+		return fmt.Errorf("%s version %s is older than the minimum supported version %s", name, currentVersion, minimumVersion)
+	}
+	return nil
+}
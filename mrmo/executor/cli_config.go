@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cliConfigFile is the path, relative to a config directory, of the CLI config written for each run that installs
+// providers from a filesystem mirror
+const cliConfigFile = ".mrmo/cli.tfrc"
+
+// providerInstallation configures where providers are installed from, so that init can work without internet access
+type providerInstallation struct {
+	// pluginCacheDir is shared by every config directory, so each provider version is only downloaded once
+	pluginCacheDir string
+	// filesystemMirror, if set, is the only source providers are installed from
+	filesystemMirror string
+	// userCliConfig is the CLI config the binary would read if it were run directly, if there is one. It is merged
+	// into the CLI config written for the filesystem mirror, so that settings such as credentials still apply.
+	userCliConfig string
+}
+
+// newProviderInstallation resolves the configured plugin cache and filesystem mirror to absolute paths, creating the
+// plugin cache if it does not exist. The plugin cache defaults to a directory in the user's cache directory.
+func newProviderInstallation(cfg config.Executor, name string) (*providerInstallation, error) {
+	pluginCacheDir := cfg.PluginCacheDir
+	if pluginCacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find user cache directory for the plugin cache: %w", err)
+		}
+		pluginCacheDir = filepath.Join(userCacheDir, "mrmo", "plugin-cache")
+	}
+
+	pluginCacheDir, err := filepath.Abs(pluginCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(pluginCacheDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create plugin cache directory '%s': %w", pluginCacheDir, err)
+	}
+
+	p := providerInstallation{pluginCacheDir: pluginCacheDir, userCliConfig: userCliConfigPath(name)}
+	if cfg.FilesystemMirror != "" {
+		if p.filesystemMirror, err = filepath.Abs(cfg.FilesystemMirror); err != nil {
+			return nil, err
+		}
+		if _, err = os.Stat(p.filesystemMirror); err != nil {
+			return nil, fmt.Errorf("filesystem mirror '%s' is not accessible: %w", p.filesystemMirror, err)
+		}
+	}
+	return &p, nil
+}
+
+// userCliConfigPath returns the CLI config the named binary reads by default: the file TF_CLI_CONFIG_FILE points at or,
+// failing that, the one in the user's home directory. It returns an empty string if there is none.
+func userCliConfigPath(name string) string {
+	if path := os.Getenv("TF_CLI_CONFIG_FILE"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	candidates := []string{filepath.Join(home, ".terraformrc")}
+	if name == TypeOpenTofu {
+		candidates = append([]string{filepath.Join(home, ".tofurc")}, candidates...)
+	}
+	for _, candidate := range candidates {
+		if _, err = os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// env returns the environment variables pointing the binary at the plugin cache and, if providers are installed from a
+// filesystem mirror, at the CLI config written into dir. The plugin cache is set through environment variables, which
+// take precedence over the user's CLI config without replacing it. Cached providers are only used once the dependency
+// lock file holds their checksums, so that they are still verified (see lockArgs).
+func (p *providerInstallation) env(dir string) ([]string, error) {
+	env := []string{"TF_PLUGIN_CACHE_DIR=" + p.pluginCacheDir}
+	if p.filesystemMirror == "" {
+		return env, nil
+	}
+
+	cliConfigPath, err := p.writeCliConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	return append(env, "TF_CLI_CONFIG_FILE="+cliConfigPath), nil
+}
+
+// lockArgs returns the arguments recording the checksums of the providers in the filesystem mirror in the dependency
+// lock file, so that init can install them from the plugin cache in a new config directory. It returns nil if providers
+// are not installed from a filesystem mirror, in which case init records the checksums from the registry.
+func (p *providerInstallation) lockArgs() []string {
+	if p.filesystemMirror == "" {
+		return nil
+	}
+	return []string{"providers", "lock", "-fs-mirror=" + p.filesystemMirror}
+}
+
+// cliConfig renders the CLI configuration file installing providers from the filesystem mirror. The user's CLI config
+// is copied into it, without its own provider_installation block, since only one may be declared.
+func (p *providerInstallation) cliConfig() (string, error) {
+	var b strings.Builder
+	if p.userCliConfig != "" {
+		userConfig, err := os.ReadFile(p.userCliConfig)
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read CLI config '%s': %w", p.userCliConfig, err)
+		}
+		if len(userConfig) > 0 {
+			fmt.Fprintf(&b, "# from %s\n", p.userCliConfig)
+			b.WriteString(strings.TrimSpace(removeBlock(string(userConfig), "provider_installation")))
+			b.WriteString("\n\n")
+		}
+	}
+
+	b.WriteString("provider_installation {\n")
+	b.WriteString("  filesystem_mirror {\n")
+	fmt.Fprintf(&b, "    path = %q\n", p.filesystemMirror)
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// removeBlock returns config without any top level block of the given type. Braces inside strings and comments are
+// not expected in the blocks being removed.
+func removeBlock(config, blockType string) string {
+	var b strings.Builder
+	depth := 0
+	removing := false
+	for _, line := range strings.SplitAfter(config, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if depth == 0 && !removing && (trimmed == blockType || strings.HasPrefix(trimmed, blockType+" ") || strings.HasPrefix(trimmed, blockType+"{")) {
+			removing = true
+		}
+		if !removing {
+			b.WriteString(line)
+			continue
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 0 && strings.Contains(line, "}") {
+			removing = false
+			depth = 0
+		}
+	}
+	return b.String()
+}
+
+// fingerprint summarises the provider installation settings, including the CLI config written for the filesystem
+// mirror, so that init is run again when they change
+func (p *providerInstallation) fingerprint() (string, error) {
+	fingerprint := fmt.Sprintf("plugin_cache_dir = %q\n", p.pluginCacheDir)
+	if p.filesystemMirror == "" {
+		return fingerprint, nil
+	}
+	cliConfig, err := p.cliConfig()
+	if err != nil {
+		return "", err
+	}
+	return fingerprint + cliConfig, nil
+}
+
+// writeCliConfig writes the CLI configuration file into dir and returns its absolute path
+func (p *providerInstallation) writeCliConfig(dir string) (string, error) {
+	path, err := filepath.Abs(filepath.Join(dir, cliConfigFile))
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create directory for CLI config '%s': %w", path, err)
+	}
+	cliConfig, err := p.cliConfig()
+	if err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(path, []byte(cliConfig), 0644); err != nil {
+		return "", fmt.Errorf("failed to write CLI config '%s': %w", path, err)
+	}
+	return path, nil
+}
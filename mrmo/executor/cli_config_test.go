@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnitProviderInstallationEnvWithoutMirror(t *testing.T) {
+	dir := t.TempDir()
+	p := providerInstallation{pluginCacheDir: "/cache"}
+
+	env, err := p.env(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"TF_PLUGIN_CACHE_DIR=/cache"}
+	if strings.Join(env, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected env %v, got %v", expected, env)
+	}
+	if _, err = os.Stat(filepath.Join(dir, cliConfigFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no CLI config to be written, so that the user's own is used (%v)", err)
+	}
+	if args := p.lockArgs(); args != nil {
+		t.Errorf("expected the lock file to be left to init without a mirror, got %v", args)
+	}
+}
+
+func TestUnitInitLocksProvidersFromMirror(t *testing.T) {
+	dir := t.TempDir()
+	mirror := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	binaryPath := filepath.Join(dir, "fake-tofu")
+	// every run appends its arguments and environment to argsFile
+	script := "#!/bin/sh\necho \"$* $TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE\" >> '" + argsFile + "'\n"
+	if err := os.WriteFile(binaryPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := &cliExecutor{
+		name:                 TypeOpenTofu,
+		binaryPath:           binaryPath,
+		timeouts:             defaultTimeouts,
+		providerInstallation: &providerInstallation{pluginCacheDir: t.TempDir(), filesystemMirror: mirror},
+	}
+
+	if diags := c.Init(context.Background(), dir); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	expected := []string{"providers lock -fs-mirror=" + mirror + " ", "init -input=false "}
+	if runs := readArgs(t, argsFile); strings.Join(runs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected the lock file to be filled from the mirror before init, with the cache verified against it, got %q", runs)
+	}
+}
+
+func TestUnitWriteCliConfig(t *testing.T) {
+	dir := t.TempDir()
+	userCliConfig := filepath.Join(t.TempDir(), ".tofurc")
+	userConfig := `credentials "app.terraform.io" {
+  token = "secret"
+}
+
+provider_installation {
+  network_mirror {
+    url = "https://mirror.example.com/"
+  }
+  direct {}
+}
+
+disable_checkpoint = true
+`
+	if err := os.WriteFile(userCliConfig, []byte(userConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := providerInstallation{pluginCacheDir: "/cache", filesystemMirror: "/mirror", userCliConfig: userCliConfig}
+
+	env, err := p.env(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, cliConfigFile)
+	if env[len(env)-1] != "TF_CLI_CONFIG_FILE="+path {
+		t.Errorf("expected TF_CLI_CONFIG_FILE to point at '%s', got %v", path, env)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := string(written)
+	for _, expected := range []string{`credentials "app.terraform.io" {`, "disable_checkpoint = true", `path = "/mirror"`} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected the CLI config to contain '%s', got:\n%s", expected, config)
+		}
+	}
+	if strings.Count(config, "provider_installation") != 1 || strings.Contains(config, "network_mirror") || strings.Contains(config, "direct") {
+		t.Errorf("expected the user's provider_installation block to be replaced, got:\n%s", config)
+	}
+
+	// a user config that does not exist is skipped
+	p.userCliConfig = filepath.Join(t.TempDir(), "missing")
+	if _, err = p.writeCliConfig(dir); err != nil {
+		t.Fatal(err)
+	}
+	if written, _ = os.ReadFile(path); !strings.HasPrefix(string(written), "provider_installation {") {
+		t.Errorf("expected only the filesystem mirror config, got:\n%s", written)
+	}
+}
+
+func TestUnitUserCliConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TF_CLI_CONFIG_FILE", "")
+
+	if path := userCliConfigPath(TypeOpenTofu); path != "" {
+		t.Errorf("expected no user CLI config, got '%s'", path)
+	}
+
+	terraformrc := filepath.Join(home, ".terraformrc")
+	if err := os.WriteFile(terraformrc, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tofurc := filepath.Join(home, ".tofurc")
+	if err := os.WriteFile(tofurc, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path := userCliConfigPath(TypeOpenTofu); path != tofurc {
+		t.Errorf("expected '%s', got '%s'", tofurc, path)
+	}
+	if path := userCliConfigPath(TypeTerraform); path != terraformrc {
+		t.Errorf("expected '%s', got '%s'", terraformrc, path)
+	}
+
+	t.Setenv("TF_CLI_CONFIG_FILE", "/etc/mrmo.tfrc")
+	if path := userCliConfigPath(TypeOpenTofu); path != "/etc/mrmo.tfrc" {
+		t.Errorf("expected TF_CLI_CONFIG_FILE to take precedence, got '%s'", path)
+	}
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newArgsRecordingExecutor returns an executor whose binary writes the arguments it is called with to argsFile, one
+// per line
+func newArgsRecordingExecutor(t *testing.T, dir string) (c *cliExecutor, argsFile string) {
+	t.Helper()
+	argsFile = filepath.Join(dir, "args")
+	binaryPath := filepath.Join(dir, "fake-tofu")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > '" + argsFile + "'\n"
+	if err := os.WriteFile(binaryPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &cliExecutor{name: TypeOpenTofu, binaryPath: binaryPath, timeouts: defaultTimeouts}, argsFile
+}
+
+func readArgs(t *testing.T, argsFile string) []string {
+	t.Helper()
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestUnitApplySavedPlanArgs(t *testing.T) {
+	dir := t.TempDir()
+	c, argsFile := newArgsRecordingExecutor(t, dir)
+
+	if diags := c.Apply(context.Background(), dir, ApplyOptions{PlanFile: ".mrmo/destroy.tfplan"}); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	expected := []string{"apply", "-json", "-input=false", "-auto-approve", ".mrmo/destroy.tfplan"}
+	if args := readArgs(t, argsFile); strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected args %v, got %v", expected, args)
+	}
+}
+
+func TestUnitApplyTargetArgs(t *testing.T) {
+	dir := t.TempDir()
+	c, argsFile := newArgsRecordingExecutor(t, dir)
+
+	if diags := c.Apply(context.Background(), dir, ApplyOptions{Targets: []string{"genesyscloud_group.example"}}); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	expected := []string{"apply", "-json", "-input=false", "-auto-approve", "-target", "genesyscloud_group.example"}
+	if args := readArgs(t, argsFile); strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected args %v, got %v", expected, args)
+	}
+}
+
+func TestUnitCompareVersions(t *testing.T) {
+	tests := []struct {
+		current, minimum string
+		expectError      bool
+	}{
+		{current: "1.6.0", minimum: "1.6.0"},
+		{current: "1.10.2", minimum: "1.6.0"},
+		{current: "v1.7.0", minimum: "1.6.0"},
+		{current: "1.5.7", minimum: "1.6.0", expectError: true},
+		{current: "1.6.0-beta1", minimum: "1.6.0", expectError: true},
+		{current: "unknown", minimum: "1.6.0", expectError: true},
+		{current: "1.6.0", minimum: "latest", expectError: true},
+	}
+	for _, test := range tests {
+		err := compareVersions(TypeOpenTofu, test.current, test.minimum)
+		if test.expectError && err == nil {
+			t.Errorf("expected an error comparing %s to minimum %s", test.current, test.minimum)
+		}
+		if !test.expectError && err != nil {
+			t.Errorf("unexpected error comparing %s to minimum %s: %v", test.current, test.minimum, err)
+		}
+	}
+}
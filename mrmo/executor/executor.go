@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// Executor runs the IaC tool (OpenTofu or Terraform) against a target org's config directory
+type Executor interface {
+	// Name returns the name of the IaC tool, e.g. "tofu"
+	Name() string
+	// Version returns the version of the IaC tool binary
+	Version(ctx context.Context) (string, error)
+
+	// Every run is bound to ctx. When ctx is done, or the run exceeds the timeout of its stage, the IaC tool is
+	// interrupted so that it can release its state lock, and an error diagnostic is returned.
+	Init(ctx context.Context, dir string) diag.Diagnostics
+	// MigrateState initializes dir after its backend config has changed, copying any existing state into the new backend
+	MigrateState(ctx context.Context, dir string) diag.Diagnostics
+	Plan(ctx context.Context, dir string, opts PlanOptions) (*Plan, diag.Diagnostics)
+	Apply(ctx context.Context, dir string, opts ApplyOptions) diag.Diagnostics
+	Output(ctx context.Context, dir string) (map[string]any, error)
+	Import(ctx context.Context, dir, address, id string) diag.Diagnostics
+	State(ctx context.Context, dir string) ([]StateResource, error)
+	// PullState returns the raw state of dir, or nil if there is no state yet
+	PullState(ctx context.Context, dir string) ([]byte, error)
+	// PushState replaces the state of dir with the given raw state, even if it is older than the current state
+	PushState(ctx context.Context, dir string, state []byte) diag.Diagnostics
+	// MoveState moves the resource at address from to address to in the state of dir, without changing the entity
+	MoveState(ctx context.Context, dir, from, to string) diag.Diagnostics
+}
+
+// PlanOptions configures a plan
+type PlanOptions struct {
+	// Targets limits the plan to the given resource addresses
+	Targets []string
+	// Destroy plans the destruction of the targeted resources
+	Destroy bool
+	// PlanFile is the path, relative to the config directory, the plan is saved to
+	PlanFile string
+}
+
+// ApplyOptions configures an apply. If PlanFile is set, the saved plan is applied and Targets is ignored.
+type ApplyOptions struct {
+	Targets  []string
+	PlanFile string
+}
+
+// Plan is a saved plan, along with the changes it would make
+type Plan struct {
+	File            string
+	ResourceChanges []ResourceChange
+}
+
+// ResourceChange is a change to a single resource in a plan
+type ResourceChange struct {
+	Address string   `json:"address"`
+	Actions []string `json:"actions"`
+}
+
+// IsNoOp returns true if the change leaves the resource as it is
+func (r ResourceChange) IsNoOp() bool {
+	for _, action := range r.Actions {
+		if action != "no-op" && action != "read" {
+			return false
+		}
+	}
+	return true
+}
+
+// StateResource is a resource tracked in the state of a config directory
+type StateResource struct {
+	Address string         `json:"address"`
+	Type    string         `json:"type"`
+	Name    string         `json:"name"`
+	Values  map[string]any `json:"values"`
+}
+
+const (
+	TypeOpenTofu  = "tofu"
+	TypeTerraform = "terraform"
+	// TypeInProcess applies configs in-process using the provider's resources. It is created by the mrmo package, since
+	// it needs the provider's resources and the ProviderMeta of each target.
+	TypeInProcess = "inprocess"
+)
+
+// New creates the Executor described by cfg and checks that its binary meets the minimum version, which is raised when
+// the state backend needs a newer binary
+func New(cfg config.Executor, backend config.StateBackend) (_ Executor, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to create executor: %w", err)
+		}
+	}()
+
+	var executor *cliExecutor
+	switch cfg.Type {
+	case "", TypeOpenTofu:
+		executor, err = newCliExecutor(TypeOpenTofu, cfg.BinaryPath)
+	case TypeTerraform:
+		executor, err = newCliExecutor(TypeTerraform, cfg.BinaryPath)
+	default:
+		return nil, fmt.Errorf("unknown executor type '%s'. Expected '%s' or '%s'", cfg.Type, TypeOpenTofu, TypeTerraform)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	executor.timeouts = stageTimeouts(cfg.Timeouts).withDefaults()
+	if executor.providerInstallation, err = newProviderInstallation(cfg, executor.Name()); err != nil {
+		return nil, err
+	}
+
+	if err = checkMinimumVersion(context.Background(), executor, minimumVersion(executor.Name(), cfg, backend)); err != nil {
+		return nil, err
+	}
+	return executor, nil
+}
+
+// defaultMinimumVersions are the oldest versions that support import blocks and saved plans in JSON config
+var defaultMinimumVersions = map[string]string{
+	TypeOpenTofu:  "1.6.0",
+	TypeTerraform: "1.5.0",
+}
+
+// lockfileMinimumVersions are the oldest versions whose s3 backend supports use_lockfile
+var lockfileMinimumVersions = map[string]string{
+	TypeOpenTofu:  "1.10.0",
+	TypeTerraform: "1.11.0",
+}
+
+// minimumVersion returns the oldest version of the named binary that may be used: the configured minimum, or the
+// default one, raised to the version that added use_lockfile when the s3 state backend locks state with a lock file
+func minimumVersion(name string, cfg config.Executor, backend config.StateBackend) string {
+	minimum := cfg.MinimumVersion
+	if minimum == "" {
+		minimum = defaultMinimumVersions[name]
+	}
+	if backend.Type != BackendTypeS3 || !backend.UseLockfile {
+		return minimum
+	}
+
+	lockfileMinimum, ok := lockfileMinimumVersions[name]
+	if !ok {
+		return minimum
+	}
+	configured, err := version.NewVersion(minimum)
+	if err != nil {
+		// reported by checkMinimumVersion
+		return minimum
+	}
+	if configured.LessThan(version.Must(version.NewVersion(lockfileMinimum))) {
+		return lockfileMinimum
+	}
+	return minimum
+}
@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// initStateFile is the path, relative to a config directory, of the fingerprint recorded after a successful init
+	initStateFile = ".mrmo/init-state.json"
+	lockFile      = ".terraform.lock.hcl"
+)
+
+type initState struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
+// initFingerprint summarises everything that determines the outcome of init in dir: the binary, the provider installation
+// settings, the dependency lock file, and the terraform and provider blocks of every config file.
+// Resource blocks are not included, since adding or changing resources never requires init to be run again.
+func (c *cliExecutor) initFingerprint(dir string) (string, error) {
+	hash := sha256.New()
+	write := func(label string, content []byte) {
+		_, _ = fmt.Fprintf(hash, "%s:%d:", label, len(content))
+		_, _ = hash.Write(content)
+	}
+
+	write("binary", []byte(c.binaryPath))
+	if c.providerInstallation != nil {
+		providerInstallation, err := c.providerInstallation.fingerprint()
+		if err != nil {
+			return "", err
+		}
+		write("provider_installation", []byte(providerInstallation))
+	}
+
+	lockFileContent, err := os.ReadFile(filepath.Join(dir, lockFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	write("lock_file", lockFileContent)
+
+	configFiles, err := filepath.Glob(filepath.Join(dir, "*.tf.json"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(configFiles)
+
+	for _, configFile := range configFiles {
+		content, err := os.ReadFile(configFile)
+		if err != nil {
+			return "", err
+		}
+
+		var config map[string]json.RawMessage
+		if err = json.Unmarshal(content, &config); err != nil {
+			return "", fmt.Errorf("failed to parse '%s': %w", configFile, err)
+		}
+		for _, block := range []string{"terraform", "provider"} {
+			if config[block] != nil {
+				write(filepath.Base(configFile)+":"+block, config[block])
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// needsInit returns true unless dir has been initialized and nothing affecting init has changed since
+func (c *cliExecutor) needsInit(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".terraform")); err != nil {
+		return true
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, initStateFile))
+	if err != nil {
+		return true
+	}
+
+	var state initState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return true
+	}
+
+	fingerprint, err := c.initFingerprint(dir)
+	return err != nil || fingerprint != state.Fingerprint
+}
+
+// recordInit stores the fingerprint of dir after a successful init. The fingerprint is taken after init, since init
+// may update the dependency lock file.
+func (c *cliExecutor) recordInit(dir string) error {
+	fingerprint, err := c.initFingerprint(dir)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(initState{Fingerprint: fingerprint})
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, initStateFile)
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
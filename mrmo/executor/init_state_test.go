@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnitNeedsInit(t *testing.T) {
+	dir := t.TempDir()
+	c := &cliExecutor{name: TypeOpenTofu, binaryPath: "/usr/bin/tofu"}
+
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("provider.tf.json", `{"terraform":{"required_providers":{"genesyscloud":{"version":"1.62.0"}}}}`)
+	writeFile("wrapupcode.tf.json", `{"resource":{"genesyscloud_routing_wrapupcode":{"a":{"name":"A"}}}}`)
+
+	if !c.needsInit(dir) {
+		t.Fatal("Expected uninitialized directory to need init")
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, ".terraform"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.recordInit(dir); err != nil {
+		t.Fatal(err)
+	}
+	if c.needsInit(dir) {
+		t.Fatal("Expected initialized directory not to need init")
+	}
+
+	writeFile("wrapupcode.tf.json", `{"resource":{"genesyscloud_routing_wrapupcode":{"a":{"name":"B"}}}}`)
+	if c.needsInit(dir) {
+		t.Error("Expected a resource change not to require init")
+	}
+
+	writeFile("provider.tf.json", `{"terraform":{"required_providers":{"genesyscloud":{"version":"1.64.0"}}}}`)
+	if !c.needsInit(dir) {
+		t.Error("Expected a provider version change to require init")
+	}
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// stageTimeouts adds the defaults and the mapping of commands to stages to the configured timeouts
+type stageTimeouts config.Timeouts
+
+var defaultTimeouts = stageTimeouts{
+	Init:                 10 * time.Minute,
+	Plan:                 15 * time.Minute,
+	Apply:                30 * time.Minute,
+	Read:                 5 * time.Minute,
+	InterruptGracePeriod: time.Minute,
+}
+
+// withDefaults returns a copy of t with every zero value replaced by its default
+func (t stageTimeouts) withDefaults() stageTimeouts {
+	orDefault := func(value, defaultValue time.Duration) time.Duration {
+		if value <= 0 {
+			return defaultValue
+		}
+		return value
+	}
+	return stageTimeouts{
+		Init:                 orDefault(t.Init, defaultTimeouts.Init),
+		Plan:                 orDefault(t.Plan, defaultTimeouts.Plan),
+		Apply:                orDefault(t.Apply, defaultTimeouts.Apply),
+		Read:                 orDefault(t.Read, defaultTimeouts.Read),
+		InterruptGracePeriod: orDefault(t.InterruptGracePeriod, defaultTimeouts.InterruptGracePeriod),
+	}
+}
+
+// forCommand returns the timeout of the stage the command belongs to
+func (t stageTimeouts) forCommand(command string) time.Duration {
+	switch command {
+	case "init", "providers":
+		return t.Init
+	case "plan":
+		return t.Plan
+	case "apply", "import":
+		return t.Apply
+	default:
+		return t.Read
+	}
+}
+
+// interruptOnCancel makes cmd receive SIGINT, rather than being killed, when its context is done. OpenTofu and
+// Terraform stop gracefully on SIGINT, releasing any state lock they hold. If the process has not exited after the
+// grace period, it is killed.
+func interruptOnCancel(cmd *exec.Cmd, gracePeriod time.Duration) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = gracePeriod
+}
+
+// timeoutPath is the attribute path of the diagnostic reporting a run that exceeded its stage's timeout. IsTimeout
+// checks it rather than the wording of the summary.
+var timeoutPath = cty.GetAttrPath("timeout")
+
+// StageTimeout returns the timeout of the stage command belongs to, falling back to the default of the stage
+func StageTimeout(timeouts config.Timeouts, command string) time.Duration {
+	return stageTimeouts(timeouts).withDefaults().forCommand(command)
+}
+
+// contextDiagnostic returns an error diagnostic describing why ctx ended the run of command, or nil if ctx is not done
+func (c *cliExecutor) contextDiagnostic(ctx context.Context, command, dir string, timeout time.Duration, logPath string) diag.Diagnostics {
+	diags := ContextDiagnostic(ctx, c.name, command, dir, timeout)
+	if diags != nil {
+		diags[0].Detail = fmt.Sprintf("%s was interrupted so that it could release its state lock.", c.name)
+		if logPath != "" {
+			diags[0].Detail += fmt.Sprintf(" See '%s' for the full output.", logPath)
+		}
+	}
+	return diags
+}
+
+// ContextDiagnostic returns an error diagnostic describing why ctx ended the run of command by the executor with the
+// given name, or nil if ctx is not done. A timeout is reported so that IsTimeout recognizes it.
+func ContextDiagnostic(ctx context.Context, name, command, dir string, timeout time.Duration) diag.Diagnostics {
+	d := diag.Diagnostic{
+		Severity: diag.Error,
+		Detail:   fmt.Sprintf("%s stopped before completing %s.", name, command),
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		d.Summary = fmt.Sprintf("%s %s in '%s' timed out after %s", name, command, dir, timeout)
+		d.AttributePath = timeoutPath
+	case errors.Is(ctx.Err(), context.Canceled):
+		d.Summary = fmt.Sprintf("%s %s in '%s' was cancelled", name, command, dir)
+	default:
+		return nil
+	}
+	return diag.Diagnostics{d}
+}
+
+// IsTimeout returns true if any of the diagnostics reports a run that exceeded its stage's timeout
+func IsTimeout(diags diag.Diagnostics) bool {
+	for _, d := range diags {
+		if d.Severity == diag.Error && d.AttributePath.Equals(timeoutPath) {
+			return true
+		}
+	}
+	return false
+}
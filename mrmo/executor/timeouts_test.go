@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnitRunInterruptsOnTimeout(t *testing.T) {
+	dir := t.TempDir()
+
+	// stands in for a tofu apply blocked on a provider call, which exits once interrupted
+	binaryPath := filepath.Join(dir, "fake-tofu")
+	script := "#!/bin/sh\ntrap 'kill $!; echo interrupted; exit 1' INT\nsleep 10 &\nwait\n"
+	if err := os.WriteFile(binaryPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cliExecutor{
+		name:       TypeOpenTofu,
+		binaryPath: binaryPath,
+		timeouts:   stageTimeouts{Apply: 200 * time.Millisecond, InterruptGracePeriod: 5 * time.Second}.withDefaults(),
+	}
+
+	start := time.Now()
+	diags := c.Apply(context.Background(), dir, ApplyOptions{Targets: []string{"genesyscloud_group.example"}})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the run to stop after being interrupted, took %s", elapsed)
+	}
+
+	if !IsTimeout(diags) {
+		t.Fatalf("expected a timeout diagnostic, got %v", diags)
+	}
+
+	logs, err := filepath.Glob(filepath.Join(dir, logDir, "*-apply.log"))
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("expected one apply log, got %v (%v)", logs, err)
+	}
+	output, err := os.ReadFile(logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "interrupted") {
+		t.Errorf("expected the binary to receive SIGINT, got output %q", string(output))
+	}
+}
+
+func TestUnitRunReportsCancellation(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &cliExecutor{name: TypeOpenTofu, binaryPath: "/bin/sleep", timeouts: defaultTimeouts}
+	diags := c.run(ctx, dir, "10")
+	if !diags.HasError() || IsTimeout(diags) {
+		t.Fatalf("expected a cancellation error that is not a timeout, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Summary, "was cancelled") {
+		t.Errorf("unexpected summary '%s'", diags[0].Summary)
+	}
+}
+
+func TestUnitIsTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	if diags := ContextDiagnostic(ctx, TypeOpenTofu, "apply", "dir", time.Nanosecond); !IsTimeout(diags) {
+		t.Errorf("expected a timeout, got %v", diags)
+	}
+
+	// other errors mentioning a timeout, e.g. from the provider, are not timeouts of the run
+	diags := diag.Diagnostics{{Severity: diag.Error, Summary: "tofu apply in 'dir' timed out after 1s"}}
+	if IsTimeout(diags) {
+		t.Errorf("expected an error without the timeout attribute not to be a timeout")
+	}
+}
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// logDir is the directory, relative to a config directory, where the raw output of each run is kept
+const logDir = ".mrmo/logs"
+
+// uiEvent is a single line of the machine readable UI output produced with -json
+type uiEvent struct {
+	Level      string        `json:"@level"`
+	Message    string        `json:"@message"`
+	Type       string        `json:"type"`
+	Diagnostic *uiDiagnostic `json:"diagnostic"`
+}
+
+type uiDiagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	Address  string `json:"address"`
+}
+
+// uiWriter consumes the streamed output of a run. Every line is kept in the raw log, JSON UI events are echoed as
+// human readable messages and their diagnostics are collected.
+type uiWriter struct {
+	raw     io.Writer
+	echo    io.Writer
+	pending []byte
+	diags   diag.Diagnostics
+}
+
+func (u *uiWriter) Write(p []byte) (int, error) {
+	if _, err := u.raw.Write(p); err != nil {
+		return 0, err
+	}
+
+	u.pending = append(u.pending, p...)
+	for {
+		i := bytes.IndexByte(u.pending, '\n')
+		if i < 0 {
+			break
+		}
+		u.handleLine(u.pending[:i])
+		u.pending = u.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush handles any trailing output that did not end with a newline
+func (u *uiWriter) flush() {
+	if len(u.pending) > 0 {
+		u.handleLine(u.pending)
+		u.pending = nil
+	}
+}
+
+func (u *uiWriter) handleLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	var event uiEvent
+	if err := json.Unmarshal(line, &event); err != nil || event.Message == "" {
+		// not a JSON UI event (e.g. output of a command that doesn't support -json)
+		_, _ = fmt.Fprintln(u.echo, string(line))
+		return
+	}
+
+	_, _ = fmt.Fprintln(u.echo, event.Message)
+	if event.Type == "diagnostic" && event.Diagnostic != nil {
+		u.diags = append(u.diags, toDiagnostic(*event.Diagnostic))
+	}
+}
+
+func toDiagnostic(d uiDiagnostic) diag.Diagnostic {
+	severity := diag.Warning
+	if d.Severity == "error" {
+		severity = diag.Error
+	}
+
+	summary := d.Summary
+	if d.Address != "" {
+		summary = fmt.Sprintf("%s: %s", d.Address, d.Summary)
+	}
+	return diag.Diagnostic{
+		Severity: severity,
+		Summary:  summary,
+		Detail:   d.Detail,
+	}
+}
+
+// openRunLog creates the file that keeps the raw output of a run in dir
+func openRunLog(dir, command string) (*os.File, error) {
+	logsPath := filepath.Join(dir, logDir)
+	if err := os.MkdirAll(logsPath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create log directory '%s': %w", logsPath, err)
+	}
+
+	logPath := filepath.Join(logsPath, fmt.Sprintf("%s-%s.log", time.Now().UTC().Format("20060102T150405.000000000Z"), command))
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file '%s': %w", logPath, err)
+	}
+	log.Printf("Writing %s output to '%s'", command, logPath)
+	return logFile, nil
+}
@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"bytes"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"strings"
+	"testing"
+)
+
+func TestUnitUiWriterCollectsDiagnostics(t *testing.T) {
+	output := strings.Join([]string{
+		`{"@level":"info","@message":"genesyscloud_routing_wrapupcode.example: Creating...","type":"apply_start"}`,
+		`{"@level":"warn","@message":"Warning: Deprecated attribute","type":"diagnostic","diagnostic":{"severity":"warning","summary":"Deprecated attribute","detail":"Use something else"}}`,
+		`{"@level":"error","@message":"Error: Failed to create wrapupcode","type":"diagnostic","diagnostic":{"severity":"error","summary":"Failed to create wrapupcode","detail":"409 Conflict","address":"genesyscloud_routing_wrapupcode.example"}}`,
+		`not json`,
+	}, "\n")
+
+	var raw, echo bytes.Buffer
+	writer := &uiWriter{raw: &raw, echo: &echo}
+
+	// write in uneven chunks to exercise line buffering
+	for i := 0; i < len(output); i += 7 {
+		end := min(i+7, len(output))
+		if _, err := writer.Write([]byte(output[i:end])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writer.flush()
+
+	if raw.String() != output {
+		t.Errorf("Expected raw log to contain the full output")
+	}
+
+	if len(writer.diags) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d: %v", len(writer.diags), writer.diags)
+	}
+	if writer.diags[0].Severity != diag.Warning {
+		t.Errorf("Expected first diagnostic to be a warning, got %v", writer.diags[0])
+	}
+	if d := writer.diags[1]; d.Severity != diag.Error || d.Summary != "genesyscloud_routing_wrapupcode.example: Failed to create wrapupcode" || d.Detail != "409 Conflict" {
+		t.Errorf("Unexpected error diagnostic %v", d)
+	}
+
+	if !strings.Contains(echo.String(), "Creating...") || !strings.Contains(echo.String(), "not json") {
+		t.Errorf("Expected messages to be echoed, got %q", echo.String())
+	}
+}
@@ -1,43 +1,263 @@
 package mrmo
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/tfexporter"
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
+// backendConfigFile is the name of the generated file configuring the state backend of a target org
+const (
+	backendConfigFile = "backend.tf.json"
+
+	// artifactManifestSuffix is appended to the ID of a source entity to name the manifest of its artifacts
+	artifactManifestSuffix = ".artifacts.json"
+)
+
+// FileManager manages the config directory of a target org. Files are written to and read from a local workspace
+// directory, where tofu runs. Every write and delete is mirrored to the config store; when the store is not local, the
+// workspace is synced from the store with pull before use.
 type FileManager struct {
 	targetOrgId      string
 	sourceEntityId   string
+	resourceType     string
+	resourceLabel    string
 	targetConfigDir  string
 	targetConfigFile string
 	exists           bool
+
+	store configStore.ConfigStore
+	// configPrefix is the key prefix of the config directory in the store
+	configPrefix string
+	// backupsPrefix is the key prefix of the target org's backups in the store
+	backupsPrefix string
+	// synced is true when the workspace is separate from the store
+	synced bool
+	// layout sets which file the config of the source entity is in, see layoutEntity and layoutResourceType
+	layout string
+	// history, if set, commits every write and delete to a git repository in the workspace
+	history *gitHistory
 }
 
-func newFileManager(targetOrgId, sourceEntityId string) *FileManager {
+// newFileManager returns the FileManager of the target org's config directory in the configured config store, for the
+// source entity being processed
+func (m *MrMo) newFileManager(targetOrgId string) *FileManager {
 	fm := FileManager{
-		targetOrgId:      targetOrgId,
-		sourceEntityId:   sourceEntityId,
-		targetConfigDir:  filepath.Join("mock-s3", "organizations", targetOrgId, "config"),
-		targetConfigFile: filepath.Join("mock-s3", "organizations", targetOrgId, "config", sourceEntityId+".tf.json"),
+		targetOrgId:    targetOrgId,
+		sourceEntityId: m.Id,
+		resourceType:   m.ResourceType,
+		resourceLabel:  m.ResourceLabel,
+		layout:         m.OrgManager.Layout,
+		store:          m.ConfigStore,
+		configPrefix:   path.Join("organizations", targetOrgId, "config"),
+		backupsPrefix:  path.Join("organizations", targetOrgId, "backups"),
 	}
-	fm.exists = fileExists(fm.targetConfigFile)
+	fm.targetConfigDir, fm.synced = configStore.WorkspaceDir(m.OrgManager.Storage, m.ConfigStore, fm.configPrefix)
+	fm.targetConfigFile = filepath.Join(fm.targetConfigDir, fm.entry().File)
+	fm.exists = fm.configExists()
+	fm.history = m.newGitHistory(fm.targetConfigDir, targetOrgId)
 	return &fm
 }
 
-func (f *FileManager) updateTargetTfConfig(resourceConfig util.JsonMap, delete bool) (diags diag.Diagnostics) {
+// pull syncs the workspace with the config directory in the store. Files in the store are downloaded, and files in the
+// workspace that are no longer in the store, including artifacts in subdirectories, are removed. Files generated by
+// tofu and Mr Mo in the workspace, such as the .terraform directory, state and logs, are kept.
+func (f *FileManager) pull(ctx context.Context) (diags diag.Diagnostics) {
+	if !f.synced {
+		return nil
+	}
+	defer func() {
+		f.exists = f.configExists()
+	}()
+
+	keys, err := f.store.List(ctx, f.configPrefix+"/")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	inStore := make(map[string]bool)
+	for _, key := range keys {
+		data, err := f.store.Read(ctx, key)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		relativePath := strings.TrimPrefix(key, f.configPrefix+"/")
+		if err = writeFile(filepath.Join(f.targetConfigDir, filepath.FromSlash(relativePath)), data); err != nil {
+			return diag.FromErr(err)
+		}
+		inStore[relativePath] = true
+	}
+
+	if err = f.removeFilesNotInStore(inStore); err != nil {
+		return diag.FromErr(err)
+	}
+	log.Printf("Synced '%s' from %s", f.targetConfigDir, f.store.Name())
+	return nil
+}
+
+// generatedDirs are the directories of a config directory that tofu, Mr Mo and git write to. They are never synced
+// with the store or checked against checksums.
+var generatedDirs = map[string]bool{".terraform": true, ".mrmo": true, ".git": true}
+
+// generatedFiles are the files tofu writes into a config directory, which are never synced with the store
+var generatedFiles = map[string]bool{".terraform.lock.hcl": true, "terraform.tfstate": true, "terraform.tfstate.backup": true}
+
+// removeFilesNotInStore removes every file from the workspace that is not in inStore, keyed by its slash-separated path
+// relative to the workspace, along with any directories left empty. Generated files are kept.
+func (f *FileManager) removeFilesNotInStore(inStore map[string]bool) error {
+	var emptied []string
+	err := filepath.WalkDir(f.targetConfigDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == f.targetConfigDir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if filePath == f.targetConfigDir {
+			return nil
+		}
+		if entry.IsDir() {
+			if generatedDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			emptied = append(emptied, filePath)
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(f.targetConfigDir, filePath)
+		if err != nil {
+			return err
+		}
+		if inStore[filepath.ToSlash(relativePath)] || generatedFiles[relativePath] || strings.HasSuffix(relativePath, ".tfplan") {
+			return nil
+		}
+		log.Printf("Removing '%s' from workspace. It is no longer in %s", filePath, f.store.Name())
+		if err = os.Remove(filePath); err != nil {
+			return fmt.Errorf("failed to delete file '%s': %w", filePath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// deepest first, so that a directory holding only empty directories is removed too. Directories that still hold
+	// files fail to be removed and are kept.
+	for i := len(emptied) - 1; i >= 0; i-- {
+		_ = os.Remove(emptied[i])
+	}
+	return nil
+}
+
+// push records a write of the workspace file at filePath: the file and its checksum sidecar are mirrored to the store
+// and the file is committed to the history
+func (f *FileManager) push(ctx context.Context, filePath string) diag.Diagnostics {
+	if f.synced {
+		for _, p := range []string{filePath, filePath + checksumSuffix} {
+			if p != filePath && !fileExists(p) {
+				continue
+			}
+			key, err := f.key(p)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return diag.Errorf("failed to read file '%s'. Error: %s", p, err.Error())
+			}
+			if err = f.store.Write(ctx, key, data); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+	}
+	return f.commit(ctx, filePath, "Update")
+}
+
+// unpush records a delete of the workspace file at filePath: its checksum sidecar is removed, both are deleted from the
+// store and the deletion is committed to the history
+func (f *FileManager) unpush(ctx context.Context, filePath string) diag.Diagnostics {
+	if err := removeChecksum(filePath); err != nil {
+		return diag.FromErr(err)
+	}
+	if f.synced {
+		for _, p := range []string{filePath, filePath + checksumSuffix} {
+			key, err := f.key(p)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			if err = f.store.Delete(ctx, key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return diag.FromErr(err)
+			}
+		}
+	}
+	return f.commit(ctx, filePath, "Delete")
+}
+
+// verify refuses to use a config directory holding files that do not match their checksums, e.g. because a write was
+// interrupted by a crash or the file was truncated or edited outside of Mr Mo
+func (f *FileManager) verify() diag.Diagnostics {
+	if err := verifyChecksums(f.targetConfigDir); err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Corrupted config in target org '%s'", f.targetOrgId),
+			Detail:   err.Error(),
+		}}
+	}
+	return nil
+}
+
+// commit commits the change to filePath to the history, if history is enabled
+func (f *FileManager) commit(ctx context.Context, filePath, action string) diag.Diagnostics {
+	if f.history == nil {
+		return nil
+	}
+	if err := f.history.commit(ctx, filePath, action); err != nil {
+		return diag.Errorf("failed to record history of '%s'. Error: %s", filePath, err.Error())
+	}
+	return nil
+}
+
+// key returns the key in the store of the workspace file at filePath
+func (f *FileManager) key(filePath string) (string, error) {
+	relativePath, err := filepath.Rel(f.targetConfigDir, filePath)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return "", fmt.Errorf("file '%s' is not in the config directory '%s'", filePath, f.targetConfigDir)
+	}
+	return path.Join(f.configPrefix, filepath.ToSlash(relativePath)), nil
+}
+
+func (f *FileManager) updateTargetTfConfig(ctx context.Context, resourceConfig util.JsonMap, delete bool) (diags diag.Diagnostics) {
 	if delete {
-		diags = append(diags, f.deleteResourceFile()...)
+		diags = append(diags, f.deleteResourceFile(ctx)...)
 		return
 	}
-	return append(diags, tfexporter.WriteConfigForMrMo(resourceConfig, f.targetConfigFile)...)
+
+	if f.layoutName() == layoutResourceType {
+		diags = append(diags, f.mergeIntoConfigFile(ctx, resourceConfig)...)
+		if diags.HasError() {
+			return diags
+		}
+		return append(diags, f.recordInManifest(ctx)...)
+	}
+
+	diags = append(diags, f.writeConfigFile(ctx, f.targetConfigFile, resourceConfig)...)
+	if diags.HasError() {
+		return diags
+	}
+	return append(diags, f.recordInManifest(ctx)...)
 }
 
-func (f *FileManager) deleteResourceFile() (diags diag.Diagnostics) {
+func (f *FileManager) deleteResourceFile(ctx context.Context) (diags diag.Diagnostics) {
 	if !f.exists {
 		diags = append(diags, diag.Diagnostic{
 			Severity: diag.Warning,
@@ -47,8 +267,20 @@ func (f *FileManager) deleteResourceFile() (diags diag.Diagnostics) {
 		return
 	}
 
+	if f.layoutName() == layoutResourceType {
+		diags = append(diags, f.removeFromConfigFile(ctx)...)
+		if diags.HasError() {
+			return diags
+		}
+		return append(diags, f.removeFromManifest(ctx)...)
+	}
+
 	log.Printf("Deleting file '%s'", f.targetConfigFile)
 	err := os.Remove(f.targetConfigFile)
+	if err == nil || os.IsNotExist(err) {
+		diags = append(diags, f.unpush(ctx, f.targetConfigFile)...)
+		diags = append(diags, f.removeFromManifest(ctx)...)
+	}
 	if err == nil {
 		return
 	}
@@ -60,6 +292,183 @@ func (f *FileManager) deleteResourceFile() (diags diag.Diagnostics) {
 	return append(diags, diag.Errorf("failed to delete file '%s'. Error: %s", f.targetConfigFile, err.Error())...)
 }
 
+// writeBackendConfig writes the backend config for the target org, if it differs from the one already in place.
+// Returns true if the backend config changed, in which case the existing state must be migrated.
+func (f *FileManager) writeBackendConfig(ctx context.Context, backendConfig map[string]any) (changed bool, diags diag.Diagnostics) {
+	backendFile := filepath.Join(f.targetConfigDir, backendConfigFile)
+
+	if backendConfig == nil {
+		if !fileExists(backendFile) {
+			return false, nil
+		}
+		log.Printf("Removing backend config '%s'", backendFile)
+		if err := os.Remove(backendFile); err != nil {
+			return false, diag.Errorf("failed to delete file '%s'. Error: %s", backendFile, err.Error())
+		}
+		return true, f.unpush(ctx, backendFile)
+	}
+
+	data, err := json.MarshalIndent(backendConfig, "", "  ")
+	if err != nil {
+		return false, diag.Errorf("failed to marshal backend config. Error: %s", err.Error())
+	}
+
+	if existing, err := os.ReadFile(backendFile); err == nil && string(existing) == string(data) {
+		return false, nil
+	}
+
+	log.Printf("Writing backend config '%s'", backendFile)
+	if err = writeChecksummedFile(backendFile, data); err != nil {
+		return false, diag.FromErr(err)
+	}
+	return true, f.push(ctx, backendFile)
+}
+
+// artifactManifestFile is the path of the file listing the artifacts written for the source entity in the target org
+func (f *FileManager) artifactManifestFile() string {
+	return filepath.Join(f.targetConfigDir, f.sourceEntityId+artifactManifestSuffix)
+}
+
+// placeArtifacts writes the artifacts referenced by the resource config into the target config directory, each with a
+// checksum sidecar that verify checks on later runs. Artifacts that were placed by a previous run but are no longer
+// referenced are removed, and the artifact manifest is updated so the files can be cleaned up on delete. An artifact
+// whose path would leave the config directory is refused.
+func (f *FileManager) placeArtifacts(ctx context.Context, artifacts []artifact) (diags diag.Diagnostics) {
+	previousArtifacts, err := f.readArtifactManifest()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if len(artifacts) == 0 && len(previousArtifacts) == 0 {
+		return
+	}
+
+	placed := make(map[string]bool)
+	for _, a := range artifacts {
+		diags = append(diags, f.placeArtifact(ctx, a)...)
+		if diags.HasError() {
+			return
+		}
+		placed[a.RelativePath] = true
+	}
+
+	for _, a := range previousArtifacts {
+		if !placed[a.RelativePath] {
+			diags = append(diags, f.deleteArtifact(ctx, a)...)
+		}
+	}
+
+	return append(diags, f.writeArtifactManifest(ctx, artifacts)...)
+}
+
+func (f *FileManager) placeArtifact(ctx context.Context, a artifact) (diags diag.Diagnostics) {
+	fullPath, err := f.artifactPath(a.RelativePath)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if existing, err := os.ReadFile(fullPath); err == nil && checksum(existing) == a.Checksum {
+		log.Printf("File '%s' is already up to date", fullPath)
+		return
+	}
+
+	log.Printf("Writing file '%s'", fullPath)
+	if err = writeChecksummedFile(fullPath, a.content); err != nil {
+		return diag.FromErr(err)
+	}
+	return f.push(ctx, fullPath)
+}
+
+// artifactPath returns the path of the artifact at relativePath in the target config directory, or an error if the
+// path is absolute or leaves the config directory, e.g. through "..". Artifact paths come from the exported config
+// and the artifact manifest, so they are not trusted.
+func (f *FileManager) artifactPath(relativePath string) (string, error) {
+	return configDirPath(f.targetConfigDir, relativePath)
+}
+
+// configDirPath returns the path of the file at relativePath in the config directory dir, or an error if the path is
+// absolute or leaves dir
+func configDirPath(dir, relativePath string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(relativePath))
+	if relativePath == "" || filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path '%s' is not inside the config directory '%s'", relativePath, dir)
+	}
+	return filepath.Join(dir, cleaned), nil
+}
+
+// deleteArtifacts removes every artifact listed in the artifact manifest, followed by the manifest itself
+func (f *FileManager) deleteArtifacts(ctx context.Context) (diags diag.Diagnostics) {
+	artifacts, err := f.readArtifactManifest()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	for _, a := range artifacts {
+		diags = append(diags, f.deleteArtifact(ctx, a)...)
+	}
+	if diags.HasError() {
+		return
+	}
+
+	if err = os.Remove(f.artifactManifestFile()); err != nil && !os.IsNotExist(err) {
+		return append(diags, diag.Errorf("failed to delete file '%s'. Error: %s", f.artifactManifestFile(), err.Error())...)
+	}
+	return append(diags, f.unpush(ctx, f.artifactManifestFile())...)
+}
+
+func (f *FileManager) deleteArtifact(ctx context.Context, a artifact) (diags diag.Diagnostics) {
+	fullPath, err := f.artifactPath(a.RelativePath)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("Deleting file '%s'", fullPath)
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return diag.Errorf("failed to delete file '%s'. Error: %s", fullPath, err.Error())
+	}
+	return f.unpush(ctx, fullPath)
+}
+
+func (f *FileManager) readArtifactManifest() ([]artifact, error) {
+	return readArtifactManifestFile(f.artifactManifestFile())
+}
+
+// readArtifactManifestFile returns the artifacts listed in the artifact manifest at manifestPath, or nil if there is
+// no manifest
+func readArtifactManifestFile(manifestPath string) ([]artifact, error) {
+	data, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file '%s'. Error: %w", manifestPath, err)
+	}
+
+	var artifacts []artifact
+	if err = json.Unmarshal(data, &artifacts); err != nil {
+		return nil, fmt.Errorf("failed to parse file '%s'. Error: %w", manifestPath, err)
+	}
+	return artifacts, nil
+}
+
+func (f *FileManager) writeArtifactManifest(ctx context.Context, artifacts []artifact) diag.Diagnostics {
+	if len(artifacts) == 0 {
+		if err := os.Remove(f.artifactManifestFile()); err != nil && !os.IsNotExist(err) {
+			return diag.Errorf("failed to delete file '%s'. Error: %s", f.artifactManifestFile(), err.Error())
+		}
+		return f.unpush(ctx, f.artifactManifestFile())
+	}
+
+	data, err := json.MarshalIndent(artifacts, "", "  ")
+	if err != nil {
+		return diag.Errorf("failed to marshal artifact manifest. Error: %s", err.Error())
+	}
+	if err = writeChecksummedFile(f.artifactManifestFile(), data); err != nil {
+		return diag.FromErr(err)
+	}
+	return f.push(ctx, f.artifactManifestFile())
+}
+
 func fileExists(filePath string) bool {
 	var err error
 	if _, err = os.Stat(filePath); err == nil {
@@ -0,0 +1,156 @@
+package mrmo
+
+import (
+	"context"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnitFileManagerSyncsWorkspace(t *testing.T) {
+	ctx := context.Background()
+	store := configStore.NewLocalStore(t.TempDir())
+	workspace := t.TempDir()
+
+	fm := &FileManager{
+		targetOrgId:     "org-a",
+		targetConfigDir: workspace,
+		store:           store,
+		configPrefix:    "organizations/org-a/config",
+		synced:          true,
+	}
+
+	if err := store.Write(ctx, "organizations/org-a/config/source-1.tf.json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Write(ctx, "organizations/org-a/config/prompts/kept.wav", []byte("RIFF")); err != nil {
+		t.Fatal(err)
+	}
+	for _, stale := range []string{"stale.tf.json", "prompts/stale.wav", "flows/old/inbound.yaml", "flows/old/inbound.yaml" + checksumSuffix} {
+		if err := writeFile(filepath.Join(workspace, stale), []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, generated := range []string{".terraform/providers/registry", "terraform.tfstate", ".terraform.lock.hcl"} {
+		if err := writeFile(filepath.Join(workspace, generated), []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if diags := fm.pull(ctx); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	for _, downloaded := range []string{"source-1.tf.json", "prompts/kept.wav"} {
+		if !fileExists(filepath.Join(workspace, downloaded)) {
+			t.Errorf("expected '%s' in the store to be downloaded", downloaded)
+		}
+	}
+	for _, stale := range []string{"stale.tf.json", "prompts/stale.wav", "flows"} {
+		if fileExists(filepath.Join(workspace, stale)) {
+			t.Errorf("expected '%s', which is not in the store, to be removed", stale)
+		}
+	}
+	for _, generated := range []string{".terraform/providers/registry", "terraform.tfstate", ".terraform.lock.hcl"} {
+		if !fileExists(filepath.Join(workspace, generated)) {
+			t.Errorf("expected the generated '%s' to be kept", generated)
+		}
+	}
+
+	if diags := fm.writeProviderConfig(ctx, orgManager.OrgData{OrgId: "org-a"}, ""); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if _, err := store.Read(ctx, "organizations/org-a/config/"+providerConfigFile); err != nil {
+		t.Errorf("expected the provider config to be pushed to the store: %v", err)
+	}
+}
+
+func newTestArtifact(relativePath, content string) artifact {
+	return artifact{RelativePath: relativePath, Checksum: checksum([]byte(content)), content: []byte(content)}
+}
+
+func TestUnitPlaceArtifacts(t *testing.T) {
+	ctx := context.Background()
+	fm := newTestMrMo(t).newFileManager(testOrgId)
+
+	artifacts := []artifact{newTestArtifact("prompts/hello.wav", "RIFF"), newTestArtifact("flows/inbound.yaml", "inboundCall:")}
+	if diags := fm.placeArtifacts(ctx, artifacts); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	for _, a := range artifacts {
+		if content := readTestFile(t, fm.targetConfigDir, a.RelativePath); content != string(a.content) {
+			t.Errorf("expected '%s' to be written, got '%s'", a.RelativePath, content)
+		}
+	}
+	if err := verifyChecksums(fm.targetConfigDir); err != nil {
+		t.Errorf("expected the artifacts to match their checksums: %v", err)
+	}
+
+	// an artifact that is no longer referenced is removed
+	if diags := fm.placeArtifacts(ctx, artifacts[:1]); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if fileExists(filepath.Join(fm.targetConfigDir, "flows/inbound.yaml")) {
+		t.Error("expected the unreferenced flow file to be removed")
+	}
+	if manifest, err := fm.readArtifactManifest(); err != nil || len(manifest) != 1 || manifest[0].RelativePath != "prompts/hello.wav" {
+		t.Errorf("expected the manifest to list only the prompt, got %+v (%v)", manifest, err)
+	}
+}
+
+func TestUnitPlaceArtifactsOutsideConfigDir(t *testing.T) {
+	ctx := context.Background()
+
+	for _, relativePath := range []string{"../escape.yaml", "prompts/../../escape.yaml", "/tmp/escape.yaml", "", "."} {
+		fm := newTestMrMo(t).newFileManager(testOrgId)
+		if diags := fm.placeArtifacts(ctx, []artifact{newTestArtifact(relativePath, "content")}); !diags.HasError() {
+			t.Errorf("expected artifact path '%s' to be refused", relativePath)
+		}
+		if fileExists(filepath.Join(filepath.Dir(fm.targetConfigDir), "escape.yaml")) {
+			t.Errorf("expected nothing to be written outside the config directory for '%s'", relativePath)
+		}
+	}
+
+	// paths that only pass through a parent directory stay inside the config directory
+	fm := newTestMrMo(t).newFileManager(testOrgId)
+	if diags := fm.placeArtifacts(ctx, []artifact{newTestArtifact("prompts/../flows/inbound.yaml", "content")}); diags.HasError() {
+		t.Errorf("unexpected error: %v", diags)
+	}
+	if !fileExists(filepath.Join(fm.targetConfigDir, "flows", "inbound.yaml")) {
+		t.Error("expected the artifact to be written inside the config directory")
+	}
+}
+
+func TestUnitDeleteArtifacts(t *testing.T) {
+	ctx := context.Background()
+	fm := newTestMrMo(t).newFileManager(testOrgId)
+
+	artifacts := []artifact{newTestArtifact("prompts/hello.wav", "RIFF"), newTestArtifact("flows/inbound.yaml", "inboundCall:")}
+	if diags := fm.placeArtifacts(ctx, artifacts); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if diags := fm.deleteArtifacts(ctx); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	for _, file := range []string{"prompts/hello.wav", "flows/inbound.yaml", "source-1.artifacts.json"} {
+		if fileExists(filepath.Join(fm.targetConfigDir, file)) || fileExists(filepath.Join(fm.targetConfigDir, file+checksumSuffix)) {
+			t.Errorf("expected '%s' and its checksum to be deleted", file)
+		}
+	}
+
+	// a manifest pointing outside of the config directory is refused rather than followed
+	outside := filepath.Join(filepath.Dir(fm.targetConfigDir), "keep.txt")
+	if err := os.WriteFile(outside, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(fm.artifactManifestFile(), []byte(`[{"relativePath":"../keep.txt"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if diags := fm.deleteArtifacts(ctx); !diags.HasError() {
+		t.Error("expected an error for an artifact path outside the config directory")
+	}
+	if !fileExists(outside) {
+		t.Error("expected the file outside the config directory to be kept")
+	}
+}
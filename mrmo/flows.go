@@ -0,0 +1,86 @@
+package mrmo
+
+import (
+	"bytes"
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const flowResourceType = "genesyscloud_flow"
+
+// resolveGuidsInFlowFile resolves the GUIDs in the architect flow YAML at relativePath to the GUIDs of the target org
+// stored in the mapping table.
+//
+// Parameters:
+//   - content: The flow YAML exported from the source org
+//   - relativePath: The path of the flow YAML, used in diagnostics
+//   - target: The target org data
+//
+// Returns:
+//   - []byte: The flow YAML for the target org
+//   - diag.Diagnostics: A diagnostic for every GUID that has no mapping for the target org. Such GUIDs are left in
+//     place. The severity is an error in strict mode and a warning otherwise.
+//
+// Only whole GUIDs are replaced, at the positions they were found, so a GUID that is part of a longer value is left
+// alone and replacing one GUID can never change another. The resolved YAML differs from the exported file, so checksums
+// of the exported file, such as a file_content_hash in the exported config, do not match the file written to the
+// target config directory.
+func (m *MrMo) resolveGuidsInFlowFile(content []byte, relativePath string, target orgManager.OrgData) (_ []byte, diags diag.Diagnostics) {
+	targetGuids := make(map[string]string)
+	unresolved := make(map[string]bool)
+
+	var resolved bytes.Buffer
+	last := 0
+	for _, match := range findWholeGuidIndexes(content) {
+		start, end := match[0], match[1]
+		guid := string(content[start:end])
+		targetGuid, ok := targetGuids[guid]
+		if !ok && !unresolved[guid] {
+			var err error
+			targetGuid, err = mockDynamo.GetTargetIdBySourceId(guid, target.OrgId)
+			if err == nil && targetGuid != "" {
+				targetGuids[guid] = targetGuid
+				ok = true
+			} else {
+				unresolved[guid] = true
+				line := bytes.Count(content[:start], []byte("\n")) + 1
+				diags = append(diags, m.unresolvedFlowGuidDiagnostic(guid, relativePath, line, target))
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		resolved.Write(content[last:start])
+		resolved.WriteString(targetGuid)
+		last = end
+	}
+	resolved.Write(content[last:])
+	return resolved.Bytes(), diags
+}
+
+// isGuidBoundary returns true if the byte at i of content cannot be part of the same token as an adjacent GUID
+func isGuidBoundary(content []byte, i int) bool {
+	if i < 0 || i >= len(content) {
+		return true
+	}
+	c := content[i]
+	return !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_')
+}
+
+// unresolvedFlowGuidDiagnostic builds the diagnostic reported when a GUID in a flow YAML has no mapping for the target
+// org. The severity is an error in strict mode and a warning otherwise.
+func (m *MrMo) unresolvedFlowGuidDiagnostic(guid, relativePath string, line int, target orgManager.OrgData) diag.Diagnostic {
+	severity := diag.Warning
+	if m.StrictMode {
+		severity = diag.Error
+	}
+	return diag.Diagnostic{
+		Severity: severity,
+		Summary:  fmt.Sprintf("Unresolved GUID in flow file '%s'", relativePath),
+		Detail: fmt.Sprintf("'%s' on line %d has no mapping for target org '%s' (%s), and was left in place.",
+			guid, line, target.Name, target.OrgId),
+	}
+}
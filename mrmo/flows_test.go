@@ -0,0 +1,70 @@
+package mrmo
+
+import (
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"testing"
+)
+
+const (
+	flowTestQueueId  = "11111111-1111-1111-1111-111111111111"
+	flowTestTargetId = "22222222-2222-2222-2222-222222222222"
+	flowTestUnmapped = "33333333-3333-3333-3333-333333333333"
+)
+
+func TestUnitResolveGuidsInFlowFile(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a", Name: "Org A"}
+	mockDynamo.UseTestTable(t, mappingItem("genesyscloud_routing_queue", flowTestQueueId, target.OrgId, flowTestTargetId))
+
+	content := "inboundCall:\n" +
+		"  queue: " + flowTestQueueId + "\n" +
+		"  queueAgain: \"" + flowTestQueueId + "\"\n" +
+		"  token: x" + flowTestQueueId + "\n" +
+		"  other: " + flowTestUnmapped + "\n"
+	expected := "inboundCall:\n" +
+		"  queue: " + flowTestTargetId + "\n" +
+		"  queueAgain: \"" + flowTestTargetId + "\"\n" +
+		"  token: x" + flowTestQueueId + "\n" +
+		"  other: " + flowTestUnmapped + "\n"
+
+	m := &MrMo{}
+	resolved, diags := m.resolveGuidsInFlowFile([]byte(content), "flows/inbound.yaml", target)
+	if string(resolved) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, resolved)
+	}
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("expected a warning for the unmapped GUID, got %v", diags)
+	}
+
+	m.StrictMode = true
+	if _, diags = m.resolveGuidsInFlowFile([]byte(content), "flows/inbound.yaml", target); !diags.HasError() {
+		t.Errorf("expected an error for the unmapped GUID in strict mode, got %v", diags)
+	}
+}
+
+func TestUnitPrepareArtifactsForTarget(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a"}
+	mockDynamo.UseTestTable(t, mappingItem("genesyscloud_routing_queue", flowTestQueueId, target.OrgId, flowTestTargetId))
+
+	content := []byte("queue: " + flowTestQueueId + "\n")
+	m := &MrMo{
+		ResourceType: flowResourceType,
+		Artifacts:    []artifact{{RelativePath: "flows/inbound.yaml", Checksum: checksum(content), content: content}},
+	}
+
+	artifacts, diags := m.prepareArtifactsForTarget(target)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	resolved := "queue: " + flowTestTargetId + "\n"
+	if len(artifacts) != 1 || string(artifacts[0].content) != resolved {
+		t.Fatalf("expected the flow file to be resolved, got %+v", artifacts)
+	}
+	if artifacts[0].Checksum != checksum([]byte(resolved)) {
+		t.Error("expected the checksum to be recomputed from the resolved content")
+	}
+	if string(m.Artifacts[0].content) != string(content) {
+		t.Error("expected the source artifact to be left unchanged")
+	}
+}
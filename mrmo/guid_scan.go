@@ -0,0 +1,124 @@
+package mrmo
+
+import (
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/tfexporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"log"
+	"sort"
+)
+
+// guidLocation is a GUID found in a resource config, along with the attribute path it was found at
+type guidLocation struct {
+	Path string
+	Guid string
+}
+
+// resolveUnlistedGuids scans the whole resource block for GUID-shaped strings that are not declared in the exporter's
+// RefAttrs and resolves them to target org GUIDs using the mapping table.
+//
+// Parameters:
+//   - resourceConfig: The exported config, with RefAttrs references already resolved
+//   - target: The target org data
+//
+// Returns:
+//   - util.JsonMap: A copy of resourceConfig with every GUID that has a mapping replaced
+//   - diag.Diagnostics: A warning for each GUID that could not be resolved, naming the attribute path it was found at
+func (m *MrMo) resolveUnlistedGuids(resourceConfig util.JsonMap, target orgManager.OrgData) (_ util.JsonMap, diags diag.Diagnostics) {
+	resourceBlock, ok := resourceConfig["resource"].(map[string]tfexporter.ResourceJSONMaps)
+	if !ok {
+		return resourceConfig, diag.Errorf("failed to read resource block from config for '%s'", m.ResourcePath)
+	}
+	individualResourceConfig := resourceBlock[m.ResourceType][m.ResourceLabel]
+
+	locations := m.findUnlistedGuids(individualResourceConfig)
+	log.Printf("Found %d GUIDs not declared in RefAttrs for resource %s", len(locations), m.ResourcePath)
+
+	replacements := make(guidReplacements)
+	for _, location := range locations {
+		targetGuid, lookupErr := mockDynamo.GetTargetIdBySourceId(location.Guid, target.OrgId)
+		if lookupErr != nil || targetGuid == "" {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Unresolved GUID in %s", m.ResourcePath),
+				Detail: fmt.Sprintf("GUID '%s' at attribute '%s' is not declared in RefAttrs and has no mapping for target org '%s'.",
+					location.Guid, location.Path, target.OrgId),
+			})
+			continue
+		}
+
+		replacements.add(location.Path, location.Guid, targetGuid)
+	}
+
+	resourceConfig, err := m.replaceGuidsInResourceConfig(resourceConfig, replacements)
+	if err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+
+	return resourceConfig, diags
+}
+
+// findUnlistedGuids returns the location of every GUID in the resource config that was not already collected using
+// the exporter's RefAttrs
+func (m *MrMo) findUnlistedGuids(individualResourceConfig util.JsonMap) (unlisted []guidLocation) {
+	listed := make(map[string]bool)
+	if m.Exporter != nil {
+		for path := range m.Exporter.RefAttrs {
+			for _, value := range getValuesAtPath(individualResourceConfig, path) {
+				if guid, ok := value.(string); ok {
+					listed[guid] = true
+				}
+			}
+		}
+	}
+
+	for _, location := range findGuidLocations(map[string]any(individualResourceConfig), "") {
+		if listed[location.Guid] || location.Guid == m.Id {
+			continue
+		}
+		unlisted = append(unlisted, location)
+	}
+	return
+}
+
+// findGuidLocations walks v and returns every GUID found in a string value, along with its attribute path. List
+// elements are addressed by index, e.g. "member_groups[0].member_group_id".
+func findGuidLocations(v any, path string) (locations []guidLocation) {
+	joinPath := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch typed := v.(type) {
+	case string:
+		for _, guid := range findWholeGuids(typed) {
+			locations = append(locations, guidLocation{Path: path, Guid: guid})
+		}
+	case []string:
+		for i, element := range typed {
+			locations = append(locations, findGuidLocations(element, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case []any:
+		for i, element := range typed {
+			locations = append(locations, findGuidLocations(element, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case util.JsonMap:
+		return findGuidLocations(map[string]any(typed), path)
+	case map[string]any:
+		// sort keys so that results are deterministic
+		keys := make([]string, 0, len(typed))
+		for k := range typed {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			locations = append(locations, findGuidLocations(typed[k], joinPath(k))...)
+		}
+	}
+	return
+}
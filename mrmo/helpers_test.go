@@ -0,0 +1,208 @@
+package mrmo
+
+import (
+	"context"
+	"encoding/json"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider"
+	resourceExporter "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// testOrgId is the target org of tests that need only one
+	testOrgId         = "org-a"
+	testResourceType  = "genesyscloud_group"
+	testResourceLabel = "example"
+)
+
+// newTestMrMo returns a MrMo processing message-1 for source-1, a genesyscloud_group labelled "example", with a fake
+// executor, an empty org config and its config store in a temporary directory. Tests set the fields they depend on.
+func newTestMrMo(t *testing.T) *MrMo {
+	return &MrMo{
+		Id:            "source-1",
+		ResourceType:  testResourceType,
+		ResourceLabel: testResourceLabel,
+		ResourcePath:  testResourceType + "." + testResourceLabel,
+		MessageId:     "message-1",
+		Executor:      &fakeExecutor{},
+		ConfigStore:   configStore.NewLocalStore(t.TempDir()),
+		OrgManager:    &orgManager.OrgManager{},
+		ProviderMeta:  &provider.ProviderMeta{},
+	}
+}
+
+// newTestFileManager returns the FileManager of org-a for the given source entity and label, in the config store and
+// layout of m
+func newTestFileManager(m *MrMo, sourceEntityId, label string) *FileManager {
+	m.Id, m.ResourceLabel = sourceEntityId, label
+	return m.newFileManager(testOrgId)
+}
+
+// writeTestFile writes content to the file at name in dir, with a checksum sidecar like the files Mr Mo writes
+func writeTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := writeChecksummedFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestFile returns the content of the file at name in dir
+func readTestFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// readTestConfigFile returns the parsed config file at filePath
+func readTestConfigFile(t *testing.T, filePath string) map[string]any {
+	t.Helper()
+	config, err := readConfigFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// useJsonConfigWriter writes config files as plain JSON for the duration of the test, in place of the exporter's
+// writer, which needs the provider
+func useJsonConfigWriter(t *testing.T) {
+	previous := writeTfConfig
+	writeTfConfig = func(config util.JsonMap, filePath string) diag.Diagnostics {
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err == nil {
+			err = os.WriteFile(filePath, data, 0644)
+		}
+		return diag.FromErr(err)
+	}
+	t.Cleanup(func() {
+		writeTfConfig = previous
+	})
+}
+
+// mappingItem returns an item of the mapping table mapping the source entity to the target entity in org
+func mappingItem(resourceType, sourceEntityId, orgId, targetEntityId string) mockDynamo.Item {
+	return mockDynamo.Item{
+		ResourceType:   resourceType,
+		SourceEntityId: sourceEntityId,
+		TargetInfo:     []mockDynamo.TargetInfo{{OrgId: orgId, TargetEntityId: targetEntityId}},
+	}
+}
+
+// withCachedEntities returns a MrMo whose entity and config caches hold the given entities of resourceType in org, keyed
+// by ID and mapped to their names. Entities are labelled the way the exporter labels them, by their sanitized name.
+func withCachedEntities(m *MrMo, org, resourceType string, names map[string]string) *MrMo {
+	configs := make(map[string]util.JsonMap, len(names))
+	for id, name := range names {
+		configs[id] = util.JsonMap{entityNameAttribute: name}
+	}
+	return withCachedEntityConfigs(m, org, resourceType, configs)
+}
+
+// withCachedEntityConfigs returns a MrMo whose entity and config caches hold the given entities of resourceType in org,
+// keyed by ID and mapped to their exported configs
+func withCachedEntityConfigs(m *MrMo, org, resourceType string, configs map[string]util.JsonMap) *MrMo {
+	if m.entityCache == nil {
+		m.entityCache = make(map[string]map[string]resourceExporter.ResourceIDMetaMap)
+	}
+	if m.entityCache[org] == nil {
+		m.entityCache[org] = make(map[string]resourceExporter.ResourceIDMetaMap)
+	}
+	if m.entityConfigCache == nil {
+		m.entityConfigCache = make(map[string]map[string]map[string]util.JsonMap)
+	}
+	if m.entityConfigCache[org] == nil {
+		m.entityConfigCache[org] = make(map[string]map[string]util.JsonMap)
+	}
+
+	entities := make(resourceExporter.ResourceIDMetaMap)
+	for id, config := range configs {
+		name, _ := config[entityNameAttribute].(string)
+		entities[id] = &resourceExporter.ResourceMeta{BlockLabel: strings.ReplaceAll(strings.ReplaceAll(name, " ", "_"), "-", "_")}
+	}
+	m.entityCache[org][resourceType] = entities
+	m.entityConfigCache[org][resourceType] = configs
+	return m
+}
+
+// fakeExecutor records the commands it is asked to run and returns canned outputs
+type fakeExecutor struct {
+	commands []string
+	applies  []executor.ApplyOptions
+	outputs  map[string]any
+	state    []executor.StateResource
+	// rawState is returned by PullState and replaced by PushState
+	rawState []byte
+}
+
+func (f *fakeExecutor) Name() string                            { return "fake" }
+func (f *fakeExecutor) Version(context.Context) (string, error) { return "1.0.0", nil }
+
+func (f *fakeExecutor) Init(context.Context, string) diag.Diagnostics {
+	f.commands = append(f.commands, "init")
+	return nil
+}
+
+func (f *fakeExecutor) MigrateState(context.Context, string) diag.Diagnostics {
+	f.commands = append(f.commands, "migrate-state")
+	return nil
+}
+
+func (f *fakeExecutor) PullState(context.Context, string) ([]byte, error) {
+	f.commands = append(f.commands, "state pull")
+	return f.rawState, nil
+}
+
+func (f *fakeExecutor) PushState(_ context.Context, _ string, state []byte) diag.Diagnostics {
+	f.commands = append(f.commands, "state push")
+	f.rawState = state
+	return nil
+}
+
+func (f *fakeExecutor) MoveState(_ context.Context, _, from, to string) diag.Diagnostics {
+	f.commands = append(f.commands, "state mv "+from+" "+to)
+	for i := range f.state {
+		if f.state[i].Address == from {
+			f.state[i].Address = to
+			f.state[i].Name = strings.TrimPrefix(to, f.state[i].Type+".")
+		}
+	}
+	return nil
+}
+
+func (f *fakeExecutor) Plan(context.Context, string, executor.PlanOptions) (*executor.Plan, diag.Diagnostics) {
+	f.commands = append(f.commands, "plan")
+	return &executor.Plan{}, nil
+}
+
+func (f *fakeExecutor) Apply(_ context.Context, _ string, opts executor.ApplyOptions) diag.Diagnostics {
+	f.commands = append(f.commands, "apply")
+	f.applies = append(f.applies, opts)
+	return nil
+}
+
+func (f *fakeExecutor) Output(context.Context, string) (map[string]any, error) {
+	f.commands = append(f.commands, "output")
+	return f.outputs, nil
+}
+
+func (f *fakeExecutor) Import(context.Context, string, string, string) diag.Diagnostics {
+	f.commands = append(f.commands, "import")
+	return nil
+}
+
+func (f *fakeExecutor) State(context.Context, string) ([]executor.StateResource, error) {
+	f.commands = append(f.commands, "state")
+	return f.state, nil
+}
@@ -0,0 +1,193 @@
+package mrmo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultHistoryDir         = ".mrmo/history"
+	defaultHistoryAuthorName  = "Mr Mo"
+	defaultHistoryAuthorEmail = "mrmo@localhost"
+)
+
+// historyExcludes keeps files generated by tofu and Mr Mo out of the history of a config directory
+var historyExcludes = strings.Join([]string{
+	".terraform/",
+	".mrmo/",
+	"terraform.tfstate",
+	"terraform.tfstate.backup",
+	"*.tfplan",
+	".*" + tmpSuffix,
+}, "\n") + "\n"
+
+// gitHistory records every change Mr Mo makes to a config directory as a commit in a git repository, so that what was
+// pushed to each org (and when) can be inspected with git log, diff and blame, and reverted. The repository is kept
+// outside of the config directory, which is its work tree, so that it is never nested in another repository such as
+// the one mock-s3 is tracked in.
+type gitHistory struct {
+	// dir is the config directory
+	dir string
+	// gitDir is the repository of the config directory
+	gitDir      string
+	authorName  string
+	authorEmail string
+
+	// trailers are added to every commit message, e.g. the source entity and message ID
+	trailers []string
+}
+
+// newGitHistory returns the history of the config directory of the source entity in the given target org
+func (m *MrMo) newGitHistory(dir, targetOrgId string) *gitHistory {
+	config := m.OrgManager.History
+	if !config.Enabled {
+		return nil
+	}
+
+	historyDir := config.Dir
+	if historyDir == "" {
+		historyDir = defaultHistoryDir
+	}
+	h := &gitHistory{
+		dir:         dir,
+		gitDir:      filepath.Join(historyDir, targetOrgId+".git"),
+		authorName:  config.AuthorName,
+		authorEmail: config.AuthorEmail,
+	}
+	if absolute, err := filepath.Abs(h.dir); err == nil {
+		h.dir = absolute
+	}
+	if absolute, err := filepath.Abs(h.gitDir); err == nil {
+		h.gitDir = absolute
+	}
+	if h.authorName == "" {
+		h.authorName = defaultHistoryAuthorName
+	}
+	if h.authorEmail == "" {
+		h.authorEmail = defaultHistoryAuthorEmail
+	}
+
+	for _, trailer := range [][2]string{
+		{"Source-Entity", m.Id},
+		{"Resource-Type", m.ResourceType},
+		{"Message-Id", m.MessageId},
+		{"Target-Org", targetOrgId},
+	} {
+		if trailer[1] != "" {
+			h.trailers = append(h.trailers, trailer[0]+": "+trailer[1])
+		}
+	}
+	return h
+}
+
+// commit commits the current content of filePath, which may have been deleted, along with its checksum sidecar. The
+// two are committed together so that reverting the commit keeps the file and its checksum in line. Nothing is
+// committed if neither changed.
+func (h *gitHistory) commit(ctx context.Context, filePath, action string) error {
+	if err := h.init(ctx); err != nil {
+		return err
+	}
+
+	relativePath, err := filepath.Rel(h.dir, filePath)
+	if err != nil {
+		return err
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	// git refuses paths that are neither on disk nor tracked, e.g. the sidecar of a file written without a checksum
+	var paths []string
+	for _, p := range []string{relativePath, relativePath + checksumSuffix} {
+		if fileExists(filepath.Join(h.dir, filepath.FromSlash(p))) || h.isTracked(ctx, p) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if _, err = h.git(ctx, append([]string{"add", "--all", "--"}, paths...)...); err != nil {
+		return err
+	}
+	if !h.hasStagedChanges(ctx) {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s %s", action, relativePath)
+	if len(h.trailers) > 0 {
+		message += "\n\n" + strings.Join(h.trailers, "\n")
+	}
+	if _, err = h.git(ctx, append([]string{"commit", "--quiet", "--no-verify", "-m", message, "--"}, paths...)...); err != nil {
+		return err
+	}
+	log.Printf("Committed '%s' to the history of '%s' in '%s'", relativePath, h.dir, h.gitDir)
+	return nil
+}
+
+// init creates the repository of the config directory, if it doesn't exist yet. The repository records the config
+// directory as its work tree, so that `git --git-dir <repository>` works from anywhere.
+func (h *gitHistory) init(ctx context.Context) error {
+	if fileExists(h.gitDir) {
+		return nil
+	}
+
+	log.Printf("Initializing history of '%s' in '%s'", h.dir, h.gitDir)
+	for _, dir := range []string{h.dir, filepath.Dir(h.gitDir)} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	if _, err := h.git(ctx, "init", "--quiet"); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(h.gitDir, "info", "exclude"), []byte(historyExcludes)); err != nil {
+		return err
+	}
+	_, err := h.git(ctx, "commit", "--quiet", "--no-verify", "--allow-empty", "-m", "Initialize history")
+	return err
+}
+
+// isTracked returns true if the file at the slash separated relativePath is in the index
+func (h *gitHistory) isTracked(ctx context.Context, relativePath string) bool {
+	out, err := h.git(ctx, "ls-files", "--", relativePath)
+	return err == nil && len(bytes.TrimSpace(out)) > 0
+}
+
+// hasStagedChanges returns true if the index differs from HEAD
+func (h *gitHistory) hasStagedChanges(ctx context.Context) bool {
+	_, err := h.git(ctx, "diff", "--cached", "--quiet")
+	return err != nil
+}
+
+// git runs git in the config directory as the history's author and returns its output
+func (h *gitHistory) git(ctx context.Context, args ...string) ([]byte, error) {
+	subcommand := args[0]
+	args = append([]string{
+		"--git-dir=" + h.gitDir,
+		"--work-tree=" + h.dir,
+		"-c", "user.name=" + h.authorName,
+		"-c", "user.email=" + h.authorEmail,
+		"-c", "commit.gpgsign=false",
+	}, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = h.dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() == 0 {
+			return nil, err
+		}
+		return nil, fmt.Errorf("git %s failed in '%s': %w: %s", subcommand, h.dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+package mrmo
+
+import (
+	"context"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnitGitHistoryCommitsWritesAndDeletes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	workspace := t.TempDir()
+
+	m := &MrMo{
+		Id:           "source-1",
+		ResourceType: "genesyscloud_group",
+		MessageId:    "message-1",
+		OrgManager:   &orgManager.OrgManager{History: orgManager.HistoryConfig{Enabled: true, Dir: t.TempDir()}},
+	}
+	fm := &FileManager{
+		targetOrgId:      "org-a",
+		targetConfigDir:  workspace,
+		targetConfigFile: filepath.Join(workspace, "source-1.tf.json"),
+		history:          m.newGitHistory(workspace, "org-a"),
+	}
+
+	for _, content := range []string{`{"a":1}`, `{"a":2}`, `{"a":2}`} {
+		if err := os.WriteFile(fm.targetConfigFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if diags := fm.push(ctx, fm.targetConfigFile); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+	}
+	if err := os.Remove(fm.targetConfigFile); err != nil {
+		t.Fatal(err)
+	}
+	if diags := fm.unpush(ctx, fm.targetConfigFile); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	out, err := fm.history.git(ctx, "log", "--format=%s|%(trailers:key=Message-Id,valueonly)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commits []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			commits = append(commits, strings.TrimSpace(line))
+		}
+	}
+	expected := []string{
+		"Delete source-1.tf.json|message-1",
+		"Update source-1.tf.json|message-1",
+		"Update source-1.tf.json|message-1",
+		"Initialize history|",
+	}
+	if strings.Join(commits, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected commits:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(commits, "\n"))
+	}
+	if fileExists(filepath.Join(workspace, ".git")) {
+		t.Error("expected the repository to be kept outside of the config directory")
+	}
+	if !fileExists(filepath.Join(m.OrgManager.History.Dir, "org-a.git")) {
+		t.Errorf("expected the repository of org-a in '%s'", m.OrgManager.History.Dir)
+	}
+}
+
+func TestUnitGitHistoryRevertKeepsChecksums(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	workspace := t.TempDir()
+
+	m := &MrMo{Id: "source-1", OrgManager: &orgManager.OrgManager{History: orgManager.HistoryConfig{Enabled: true, Dir: t.TempDir()}}}
+	fm := &FileManager{
+		targetOrgId:      "org-a",
+		targetConfigDir:  workspace,
+		targetConfigFile: filepath.Join(workspace, "source-1.tf.json"),
+		history:          m.newGitHistory(workspace, "org-a"),
+	}
+
+	for _, content := range []string{`{"a":1}`, `{"a":2}`} {
+		if err := writeChecksummedFile(fm.targetConfigFile, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if diags := fm.push(ctx, fm.targetConfigFile); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+	}
+
+	if _, err := fm.history.git(ctx, "revert", "--no-edit", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fm.targetConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":1}` {
+		t.Fatalf("expected the revert to restore the first config, got %s", data)
+	}
+	if diags := fm.verify(); diags.HasError() {
+		t.Errorf("expected the reverted config to match its checksum, got %v", diags)
+	}
+}
@@ -0,0 +1,711 @@
+package mrmo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	providerRegistrar "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider_registrar"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// inProcessStateFile is the path, relative to the config directory, of the state kept by the in-process executor
+const inProcessStateFile = ".mrmo/inprocess-state.json"
+
+// inProcessExecutor applies target configs in-process, using the CRUD functions of the provider's schema.Resource
+// objects with the ProviderMeta of the target org. It needs neither a tofu binary nor provider downloads, which makes
+// replicating a single entity near-instant. It keeps its own state in each config directory, and does not support
+// state backends.
+//
+// Only what Mr Mo generates is supported: resource blocks with literal values (no interpolation), import blocks and
+// outputs referring to attributes of a resource.
+//
+// Plans, applies and imports are bound to the per-stage timeouts of the executor config, like tofu runs. The context is
+// passed to every provider call and checked before each change, so a cancelled or timed out run stops without making
+// further changes, and the state of the changes already made is saved.
+type inProcessExecutor struct {
+	m         *MrMo
+	resources map[string]*schema.Resource
+}
+
+func newInProcessExecutor(m *MrMo) (*inProcessExecutor, error) {
+	if m.OrgManager.StateBackend.Type != "" {
+		return nil, fmt.Errorf("the %s executor keeps state in each config directory and does not support a state backend", executor.TypeInProcess)
+	}
+	if m.OrgManager.Storage.Type != "" && m.OrgManager.Storage.Type != configStore.TypeLocal {
+		return nil, fmt.Errorf("the %s executor keeps state in the local workspace, so it requires the local config store", executor.TypeInProcess)
+	}
+	resources, _ := providerRegistrar.GetProviderResources()
+	return &inProcessExecutor{m: m, resources: resources}, nil
+}
+
+func (e *inProcessExecutor) Name() string {
+	return executor.TypeInProcess
+}
+
+// Version returns the version of the provider the executor runs, which is the one Mr Mo was built with
+func (e *inProcessExecutor) Version(context.Context) (string, error) {
+	return exporterProviderVersion()
+}
+
+// Init is a no-op, since there is no plugin to install
+func (e *inProcessExecutor) Init(context.Context, string) diag.Diagnostics {
+	return nil
+}
+
+func (e *inProcessExecutor) MigrateState(context.Context, string) diag.Diagnostics {
+	return nil
+}
+
+// inProcessPlan is the content of a plan file written by the in-process executor
+type inProcessPlan struct {
+	Destroy         bool                      `json:"destroy"`
+	ResourceChanges []executor.ResourceChange `json:"resource_changes"`
+}
+
+// stageContext returns ctx bounded by the configured timeout of the stage command belongs to, along with the timeout
+func (e *inProcessExecutor) stageContext(ctx context.Context, command string) (context.Context, context.CancelFunc, time.Duration) {
+	timeout := executor.StageTimeout(e.m.OrgManager.Executor.Timeouts, command)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+func (e *inProcessExecutor) Plan(ctx context.Context, dir string, opts executor.PlanOptions) (_ *executor.Plan, diags diag.Diagnostics) {
+	ctx, cancel, timeout := e.stageContext(ctx, "plan")
+	defer cancel()
+
+	config, err := readInProcessConfig(dir)
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+	state, err := readInProcessState(dir)
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	var changes []executor.ResourceChange
+	for _, address := range e.addresses(config, state, opts.Targets, opts.Destroy) {
+		if ctxDiags := executor.ContextDiagnostic(ctx, e.Name(), "plan", dir, timeout); ctxDiags != nil {
+			return nil, append(diags, ctxDiags...)
+		}
+		if opts.Destroy {
+			if state.find(address) != nil {
+				changes = append(changes, executor.ResourceChange{Address: address, Actions: []string{"delete"}})
+			}
+			continue
+		}
+
+		var actions []string
+		diags = append(diags, e.withTargetMeta(dir, func(meta any) (changeDiags diag.Diagnostics) {
+			_, _, actions, changeDiags = e.diff(ctx, address, config, state, meta)
+			return changeDiags
+		})...)
+		if diags.HasError() {
+			return nil, append(diags, executor.ContextDiagnostic(ctx, e.Name(), "plan", dir, timeout)...)
+		}
+		changes = append(changes, executor.ResourceChange{Address: address, Actions: actions})
+	}
+
+	data, err := json.MarshalIndent(inProcessPlan{Destroy: opts.Destroy, ResourceChanges: changes}, "", "  ")
+	if err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+	if err = os.WriteFile(filepath.Join(dir, opts.PlanFile), data, 0644); err != nil {
+		return nil, append(diags, diag.Errorf("failed to write plan file '%s'. Error: %s", opts.PlanFile, err.Error())...)
+	}
+	return &executor.Plan{File: opts.PlanFile, ResourceChanges: changes}, diags
+}
+
+// Apply applies the resources in the config directory (or only the targeted ones), or exactly the changes of a saved
+// plan. The state is saved after each resource, so that the entities created before a failure, a timeout or a
+// cancellation are tracked.
+func (e *inProcessExecutor) Apply(ctx context.Context, dir string, opts executor.ApplyOptions) (diags diag.Diagnostics) {
+	ctx, cancel, timeout := e.stageContext(ctx, "apply")
+	defer cancel()
+
+	config, err := readInProcessConfig(dir)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	state, err := readInProcessState(dir)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var (
+		addresses []string
+		destroy   bool
+	)
+	if opts.PlanFile != "" {
+		plan, err := readInProcessPlan(filepath.Join(dir, opts.PlanFile))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		for _, change := range plan.ResourceChanges {
+			if !change.IsNoOp() {
+				addresses = append(addresses, change.Address)
+			}
+		}
+		destroy = plan.Destroy
+	} else {
+		addresses = e.addresses(config, state, opts.Targets, false)
+	}
+
+	return e.withTargetMeta(dir, func(meta any) (diags diag.Diagnostics) {
+		for _, address := range addresses {
+			if ctxDiags := executor.ContextDiagnostic(ctx, e.Name(), "apply", dir, timeout); ctxDiags != nil {
+				return append(diags, ctxDiags...)
+			}
+
+			diags = append(diags, e.applyResource(ctx, address, config, state, meta, destroy)...)
+			if err := state.write(dir); err != nil {
+				return append(diags, diag.FromErr(err)...)
+			}
+			if diags.HasError() {
+				return append(diags, executor.ContextDiagnostic(ctx, e.Name(), "apply", dir, timeout)...)
+			}
+		}
+		return diags
+	})
+}
+
+// applyResource creates, updates, imports or deletes the resource at address so that it matches the config. If destroy is
+// set, the resource is deleted regardless of the config.
+func (e *inProcessExecutor) applyResource(ctx context.Context, address string, config *inProcessConfig, state *inProcessState, meta any, destroy bool) (diags diag.Diagnostics) {
+	resource, current, actions, diffDiags := e.diff(ctx, address, config, state, meta)
+	if destroy {
+		resource = nil
+		actions = []string{"delete"}
+		if current == nil {
+			actions = []string{"no-op"}
+		}
+	}
+	diags = append(diags, diffDiags...)
+	if diags.HasError() {
+		return diags
+	}
+	log.Printf("%s: %v", address, actions)
+
+	if len(actions) == 1 && (actions[0] == "no-op" || actions[0] == "read") {
+		if current != nil {
+			state.set(address, current)
+		}
+		return diags
+	}
+	// refreshing may have taken up the rest of the run, in which case the entity is left as it is
+	if err := ctx.Err(); err != nil {
+		return append(diags, diag.Errorf("%s: not changed: %s", address, err.Error())...)
+	}
+
+	resourceType, _ := splitAddress(address)
+	schemaResource := e.resources[resourceType]
+	if current == nil {
+		current = &terraform.InstanceState{}
+	}
+
+	var instanceDiff *terraform.InstanceDiff
+	if resource == nil {
+		instanceDiff = &terraform.InstanceDiff{Destroy: true}
+	} else {
+		var err error
+		instanceDiff, err = schemaResource.SimpleDiff(ctx, current, terraform.NewResourceConfigRaw(resource), meta)
+		if err != nil {
+			return append(diags, diag.Errorf("%s: %s", address, err.Error())...)
+		}
+	}
+
+	newState, applyDiags := schemaResource.Apply(ctx, current, instanceDiff, meta)
+	for _, d := range applyDiags {
+		d.Summary = fmt.Sprintf("%s: %s", address, d.Summary)
+		diags = append(diags, d)
+	}
+
+	if newState == nil || newState.ID == "" {
+		state.remove(address)
+	} else {
+		state.set(address, newState)
+	}
+	return diags
+}
+
+// diff refreshes the resource at address (importing it first if an import block targets it) and returns its config,
+// its current state and the actions needed to make the entity match the config
+func (e *inProcessExecutor) diff(ctx context.Context, address string, config *inProcessConfig, state *inProcessState, meta any) (resource map[string]any, current *terraform.InstanceState, actions []string, diags diag.Diagnostics) {
+	resourceType, _ := splitAddress(address)
+	schemaResource, ok := e.resources[resourceType]
+	if !ok {
+		return nil, nil, nil, diag.Errorf("%s: unknown resource type '%s'", address, resourceType)
+	}
+
+	resource, err := config.resource(address, schemaResource)
+	if err != nil {
+		return nil, nil, nil, diag.Errorf("%s: %s", address, err.Error())
+	}
+
+	current = state.find(address)
+	if current == nil && resource != nil {
+		if id, ok := config.Imports[address]; ok {
+			log.Printf("%s: importing '%s'", address, id)
+			current = &terraform.InstanceState{ID: id}
+		}
+	}
+
+	if current != nil {
+		var refreshDiags diag.Diagnostics
+		current, refreshDiags = schemaResource.RefreshWithoutUpgrade(ctx, current, meta)
+		diags = append(diags, refreshDiags...)
+		if diags.HasError() {
+			return nil, nil, nil, diags
+		}
+	}
+
+	switch {
+	case resource == nil && current == nil:
+		return nil, nil, []string{"no-op"}, diags
+	case resource == nil:
+		return nil, current, []string{"delete"}, diags
+	case current == nil:
+		return resource, nil, []string{"create"}, diags
+	}
+
+	instanceDiff, err := schemaResource.SimpleDiff(ctx, current, terraform.NewResourceConfigRaw(resource), meta)
+	if err != nil {
+		return nil, nil, nil, append(diags, diag.Errorf("%s: %s", address, err.Error())...)
+	}
+	switch {
+	case instanceDiff == nil || instanceDiff.Empty():
+		actions = []string{"no-op"}
+	case instanceDiff.RequiresNew():
+		actions = []string{"delete", "create"}
+	default:
+		actions = []string{"update"}
+	}
+	return resource, current, actions, diags
+}
+
+// addresses returns the sorted addresses to plan or apply: the targets if there are any, otherwise every resource in
+// the config and in the state. A destroy without targets covers every resource in the state.
+func (e *inProcessExecutor) addresses(config *inProcessConfig, state *inProcessState, targets []string, destroy bool) []string {
+	if len(targets) > 0 {
+		return uniqueStrings(targets)
+	}
+
+	var addresses []string
+	if !destroy {
+		for address := range config.Resources {
+			addresses = append(addresses, address)
+		}
+	}
+	for _, resource := range state.Resources {
+		addresses = append(addresses, resource.Address)
+	}
+	sort.Strings(addresses)
+	return uniqueStrings(addresses)
+}
+
+func (e *inProcessExecutor) Output(_ context.Context, dir string) (map[string]any, error) {
+	config, err := readInProcessConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	state, err := readInProcessState(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make(map[string]any)
+	for name, expression := range config.Outputs {
+		match := outputExpressionRegex.FindStringSubmatch(expression)
+		if match == nil {
+			return nil, fmt.Errorf("output '%s': unsupported expression '%s'", name, expression)
+		}
+
+		instance := state.find(match[1])
+		if instance == nil {
+			// the resource hasn't been applied yet, so the output has no value
+			continue
+		}
+		if match[2] == "id" {
+			outputs[name] = instance.ID
+		} else if value, ok := instance.Attributes[match[2]]; ok {
+			outputs[name] = value
+		}
+	}
+	return outputs, nil
+}
+
+// outputExpressionRegex matches the outputs Mr Mo generates, e.g. "${genesyscloud_group.example.id}"
+var outputExpressionRegex = regexp.MustCompile(`^\$\{([a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+)\.([a-zA-Z0-9_]+)}$`)
+
+func (e *inProcessExecutor) Import(ctx context.Context, dir, address, id string) diag.Diagnostics {
+	ctx, cancel, timeout := e.stageContext(ctx, "import")
+	defer cancel()
+
+	resourceType, _ := splitAddress(address)
+	schemaResource, ok := e.resources[resourceType]
+	if !ok {
+		return diag.Errorf("%s: unknown resource type '%s'", address, resourceType)
+	}
+
+	state, err := readInProcessState(dir)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if state.find(address) != nil {
+		return diag.Errorf("%s: resource already managed", address)
+	}
+
+	return e.withTargetMeta(dir, func(meta any) diag.Diagnostics {
+		imported, diags := schemaResource.RefreshWithoutUpgrade(ctx, &terraform.InstanceState{ID: id}, meta)
+		if diags.HasError() {
+			return append(diags, executor.ContextDiagnostic(ctx, e.Name(), "import", dir, timeout)...)
+		}
+		if imported == nil {
+			return append(diags, diag.Errorf("%s: cannot import non-existent entity '%s'", address, id)...)
+		}
+		state.set(address, imported)
+		return append(diags, diag.FromErr(state.write(dir))...)
+	})
+}
+
+func (e *inProcessExecutor) State(_ context.Context, dir string) ([]executor.StateResource, error) {
+	state, err := readInProcessState(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []executor.StateResource
+	for _, resource := range state.Resources {
+		values := map[string]any{"id": resource.Id}
+		for k, v := range resource.Attributes {
+			values[k] = v
+		}
+		resourceType, name := splitAddress(resource.Address)
+		resources = append(resources, executor.StateResource{
+			Address: resource.Address,
+			Type:    resourceType,
+			Name:    name,
+			Values:  values,
+		})
+	}
+	return resources, nil
+}
+
+func (e *inProcessExecutor) PullState(_ context.Context, dir string) ([]byte, error) {
+	state, err := os.ReadFile(filepath.Join(dir, inProcessStateFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return state, err
+}
+
+func (e *inProcessExecutor) PushState(_ context.Context, dir string, state []byte) diag.Diagnostics {
+	var parsed inProcessState
+	if err := json.Unmarshal(state, &parsed); err != nil {
+		return diag.Errorf("failed to parse state: %s", err.Error())
+	}
+	return diag.FromErr(parsed.write(dir))
+}
+
+func (e *inProcessExecutor) MoveState(_ context.Context, dir, from, to string) diag.Diagnostics {
+	state, err := readInProcessState(dir)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	instance := state.find(from)
+	if instance == nil {
+		return diag.Errorf("no resource '%s' in state", from)
+	}
+	if state.find(to) != nil {
+		return diag.Errorf("cannot move '%s': a resource '%s' is already in state", from, to)
+	}
+	state.remove(from)
+	state.set(to, instance)
+	return diag.FromErr(state.write(dir))
+}
+
+// withTargetMeta runs f with the ProviderMeta of the target org whose config directory is dir
+func (e *inProcessExecutor) withTargetMeta(dir string, f func(meta any) diag.Diagnostics) (diags diag.Diagnostics) {
+	for _, target := range e.m.OrgManager.Targets {
+		if filepath.Clean(e.m.newFileManager(target.OrgId).targetConfigDir) != filepath.Clean(dir) {
+			continue
+		}
+		err := e.m.withOrg(target, func() error {
+			diags = f(e.m.ProviderMeta)
+			return nil
+		})
+		return append(diags, diag.FromErr(err)...)
+	}
+	return diag.Errorf("no target org found for config directory '%s'", dir)
+}
+
+// splitAddress splits a resource address into its type and name
+func splitAddress(address string) (resourceType, name string) {
+	resourceType, name, _ = strings.Cut(address, ".")
+	return
+}
+
+// inProcessConfig is the part of the *.tf.json files of a config directory the in-process executor understands
+type inProcessConfig struct {
+	// Resources are the resource blocks, keyed by address
+	Resources map[string]map[string]any
+	// Imports are the IDs of the import blocks, keyed by address
+	Imports map[string]string
+	// Outputs are the value expressions of the output blocks, keyed by name
+	Outputs map[string]string
+
+	// dir is the config directory, which the paths of artifact attributes are relative to
+	dir string
+}
+
+// metaArguments are the resource block arguments handled by tofu rather than the provider
+var metaArguments = []string{"depends_on", "lifecycle", "provider", "count", "for_each"}
+
+// resource returns the config of the resource at address, in the shape expected by terraform.NewResourceConfigRaw, or
+// nil if the resource is not in the config
+func (c *inProcessConfig) resource(address string, schemaResource *schema.Resource) (map[string]any, error) {
+	resource, ok := c.Resources[address]
+	if !ok {
+		return nil, nil
+	}
+
+	resource = normalizeBlocks(schemaResource.SchemaMap(), resource)
+	for _, argument := range metaArguments {
+		delete(resource, argument)
+	}
+
+	if locations := findInterpolations(resource, ""); len(locations) > 0 {
+		return nil, fmt.Errorf("interpolation is not supported in-process. Found at %v", locations)
+	}
+
+	// tofu runs in the config directory, so the provider opens artifacts relative to it. The in-process executor runs in
+	// the working directory of the process, so the paths are made absolute.
+	resourceType, _ := splitAddress(address)
+	for _, path := range artifactAttributes[resourceType] {
+		resource = rewriteValuesAtPath(resource, path, func(value string) string {
+			if value == "" || filepath.IsAbs(value) {
+				return value
+			}
+			return filepath.Join(c.dir, value)
+		})
+	}
+	return resource, nil
+}
+
+// normalizeBlocks returns a copy of config in which nested blocks written as a single object are wrapped in a list,
+// which is how the SDK expects blocks in a raw config
+func normalizeBlocks(schemaMap map[string]*schema.Schema, config map[string]any) map[string]any {
+	normalized := make(map[string]any, len(config))
+	for k, v := range config {
+		var elem *schema.Resource
+		if s, ok := schemaMap[k]; ok {
+			elem, _ = s.Elem.(*schema.Resource)
+		}
+		if elem == nil {
+			normalized[k] = v
+			continue
+		}
+
+		var blocks []any
+		switch value := v.(type) {
+		case map[string]any:
+			blocks = []any{value}
+		case []any:
+			blocks = value
+		default:
+			normalized[k] = v
+			continue
+		}
+
+		normalizedBlocks := make([]any, 0, len(blocks))
+		for _, block := range blocks {
+			if blockMap, ok := block.(map[string]any); ok {
+				block = normalizeBlocks(elem.SchemaMap(), blockMap)
+			}
+			normalizedBlocks = append(normalizedBlocks, block)
+		}
+		normalized[k] = normalizedBlocks
+	}
+	return normalized
+}
+
+// findInterpolations returns the locations of string values in v containing template interpolation
+func findInterpolations(v any, path string) (locations []string) {
+	joinPath := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch typed := v.(type) {
+	case string:
+		if strings.Contains(strings.ReplaceAll(typed, "$${", ""), "${") {
+			locations = append(locations, path)
+		}
+	case []any:
+		for i, element := range typed {
+			locations = append(locations, findInterpolations(element, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case map[string]any:
+		// sort keys so that results are deterministic
+		keys := make([]string, 0, len(typed))
+		for k := range typed {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			locations = append(locations, findInterpolations(typed[k], joinPath(k))...)
+		}
+	}
+	return
+}
+
+// readInProcessConfig reads the resource, import and output blocks of every *.tf.json file in dir
+func readInProcessConfig(dir string) (*inProcessConfig, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &inProcessConfig{
+		Resources: make(map[string]map[string]any),
+		Imports:   make(map[string]string),
+		Outputs:   make(map[string]string),
+		dir:       dir,
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file '%s': %w", file, err)
+		}
+
+		var fileConfig struct {
+			Resource map[string]map[string]map[string]any `json:"resource"`
+			Import   []struct {
+				To string `json:"to"`
+				Id string `json:"id"`
+			} `json:"import"`
+			Output map[string]struct {
+				Value string `json:"value"`
+			} `json:"output"`
+		}
+		if err = json.Unmarshal(data, &fileConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse config file '%s': %w", file, err)
+		}
+
+		for resourceType, resources := range fileConfig.Resource {
+			for name, resource := range resources {
+				address := resourceType + "." + name
+				if _, exists := config.Resources[address]; exists {
+					return nil, fmt.Errorf("duplicate resource '%s' in config file '%s'", address, file)
+				}
+				config.Resources[address] = resource
+			}
+		}
+		for _, importBlock := range fileConfig.Import {
+			config.Imports[importBlock.To] = importBlock.Id
+		}
+		for name, output := range fileConfig.Output {
+			config.Outputs[name] = output.Value
+		}
+	}
+	return config, nil
+}
+
+func readInProcessPlan(planFile string) (*inProcessPlan, error) {
+	data, err := os.ReadFile(planFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file '%s': %w", planFile, err)
+	}
+	var plan inProcessPlan
+	if err = json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file '%s': %w", planFile, err)
+	}
+	return &plan, nil
+}
+
+// inProcessState is the state kept by the in-process executor
+type inProcessState struct {
+	// Serial is incremented on every write, like the serial of a tofu state
+	Serial    int64               `json:"serial"`
+	Resources []inProcessResource `json:"resources"`
+}
+
+// inProcessResource is the state of a single resource, in the flatmap form used by terraform.InstanceState
+type inProcessResource struct {
+	Address    string            `json:"address"`
+	Id         string            `json:"id"`
+	Attributes map[string]string `json:"attributes"`
+	Meta       map[string]any    `json:"meta,omitempty"`
+}
+
+func readInProcessState(dir string) (*inProcessState, error) {
+	statePath := filepath.Join(dir, inProcessStateFile)
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return &inProcessState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state '%s': %w", statePath, err)
+	}
+
+	var state inProcessState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state '%s': %w", statePath, err)
+	}
+	return &state, nil
+}
+
+func (s *inProcessState) write(dir string) error {
+	statePath := filepath.Join(dir, inProcessStateFile)
+	sort.Slice(s.Resources, func(i, j int) bool {
+		return s.Resources[i].Address < s.Resources[j].Address
+	})
+	s.Serial++
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return writeFile(statePath, data)
+}
+
+// find returns the instance state of the resource at address, or nil if it is not in the state
+func (s *inProcessState) find(address string) *terraform.InstanceState {
+	for _, resource := range s.Resources {
+		if resource.Address == address {
+			return &terraform.InstanceState{ID: resource.Id, Attributes: resource.Attributes, Meta: resource.Meta}
+		}
+	}
+	return nil
+}
+
+func (s *inProcessState) set(address string, instance *terraform.InstanceState) {
+	s.remove(address)
+	s.Resources = append(s.Resources, inProcessResource{
+		Address:    address,
+		Id:         instance.ID,
+		Attributes: instance.Attributes,
+		Meta:       instance.Meta,
+	})
+}
+
+func (s *inProcessState) remove(address string) {
+	for i, resource := range s.Resources {
+		if resource.Address == address {
+			s.Resources = append(s.Resources[:i], s.Resources[i+1:]...)
+			return
+		}
+	}
+}
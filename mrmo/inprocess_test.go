@@ -0,0 +1,321 @@
+package mrmo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeGroups is a stand-in for an API, keyed by ID
+type fakeGroups map[string]string
+
+func (g fakeGroups) resource() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name": {Type: schema.TypeString, Required: true},
+			"settings": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+					"enabled": {Type: schema.TypeBool, Optional: true},
+				}},
+			},
+		},
+		CreateContext: func(_ context.Context, d *schema.ResourceData, _ any) diag.Diagnostics {
+			d.SetId(fmt.Sprintf("target-%d", len(g)+1))
+			g[d.Id()] = d.Get("name").(string)
+			return nil
+		},
+		ReadContext: func(_ context.Context, d *schema.ResourceData, _ any) diag.Diagnostics {
+			name, ok := g[d.Id()]
+			if !ok {
+				d.SetId("")
+				return nil
+			}
+			_ = d.Set("name", name)
+			return nil
+		},
+		UpdateContext: func(_ context.Context, d *schema.ResourceData, _ any) diag.Diagnostics {
+			g[d.Id()] = d.Get("name").(string)
+			return nil
+		},
+		DeleteContext: func(_ context.Context, d *schema.ResourceData, _ any) diag.Diagnostics {
+			delete(g, d.Id())
+			return nil
+		},
+	}
+}
+
+func TestUnitInProcessApplyResource(t *testing.T) {
+	const address = "genesyscloud_group.example"
+	ctx := context.Background()
+	dir := t.TempDir()
+	groups := fakeGroups{}
+	e := &inProcessExecutor{resources: map[string]*schema.Resource{"genesyscloud_group": groups.resource()}}
+
+	writeConfig := func(name string) {
+		config := map[string]any{
+			"resource": map[string]any{"genesyscloud_group": map[string]any{"example": map[string]any{
+				"name":       name,
+				"settings":   map[string]any{"enabled": true},
+				"depends_on": []any{},
+			}}},
+			"output": map[string]any{buildOutputKey("source-1"): map[string]any{"value": "${" + address + ".id}"}},
+		}
+		data, _ := json.Marshal(config)
+		writeTestFile(t, dir, "source-1.tf.json", string(data))
+	}
+
+	apply := func(destroy bool) {
+		t.Helper()
+		config, err := readInProcessConfig(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, err := readInProcessState(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diags := e.applyResource(ctx, address, config, state, nil, destroy); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+		if err = state.write(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// create
+	writeConfig("Example")
+	apply(false)
+	if groups["target-1"] != "Example" {
+		t.Fatalf("expected the group to be created, got %v", groups)
+	}
+	outputs, err := e.Output(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs[buildOutputKey("source-1")] != "target-1" {
+		t.Errorf("expected the output to be the target ID, got %v", outputs)
+	}
+
+	// update
+	writeConfig("Renamed")
+	apply(false)
+	if len(groups) != 1 || groups["target-1"] != "Renamed" {
+		t.Fatalf("expected the group to be updated in place, got %v", groups)
+	}
+
+	// destroy, while the config still contains the resource
+	apply(true)
+	if len(groups) != 0 {
+		t.Fatalf("expected the group to be deleted, got %v", groups)
+	}
+	resources, err := e.State(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 0 {
+		t.Errorf("expected an empty state, got %v", resources)
+	}
+}
+
+func TestUnitInProcessMoveState(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	e := &inProcessExecutor{}
+
+	state := &inProcessState{}
+	state.set("genesyscloud_group.example", &terraform.InstanceState{ID: "target-1"})
+	state.set("genesyscloud_group.other", &terraform.InstanceState{ID: "target-2"})
+	if err := state.write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := e.MoveState(ctx, dir, "genesyscloud_group.example", "genesyscloud_group.other"); !diags.HasError() {
+		t.Error("expected a move onto a resource in state to fail")
+	}
+	if diags := e.MoveState(ctx, dir, "genesyscloud_group.example", "genesyscloud_group.example_source-1"); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	moved, err := readInProcessState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.find("genesyscloud_group.example") != nil {
+		t.Error("expected the resource to be gone from its previous address")
+	}
+	if instance := moved.find("genesyscloud_group.example_source-1"); instance == nil || instance.ID != "target-1" {
+		t.Errorf("expected target-1 at the new address, got %v", instance)
+	}
+}
+
+func TestUnitInProcessConfigRejectsInterpolation(t *testing.T) {
+	config := &inProcessConfig{Resources: map[string]map[string]any{
+		"genesyscloud_group.example": {"name": "${var.name}", "description": "$${literal}"},
+	}}
+	if _, err := config.resource("genesyscloud_group.example", fakeGroups{}.resource()); err == nil {
+		t.Error("expected interpolation to be rejected")
+	}
+}
+
+// newInProcessTestExecutor returns an in-process executor for the resources, along with the config directory of its only
+// target org. The target is also the source org, so that no client config is needed to run in it.
+func newInProcessTestExecutor(t *testing.T, resources map[string]*schema.Resource) (*inProcessExecutor, string) {
+	org := orgManager.OrgData{OrgId: testOrgId}
+	m := newTestMrMo(t)
+	m.OrgManager.Source, m.OrgManager.Targets = org, []orgManager.OrgData{org}
+	dir := m.newFileManager(org.OrgId).targetConfigDir
+	if err := os.MkdirAll(filepath.Join(dir, ".mrmo"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	return &inProcessExecutor{m: m, resources: resources}, dir
+}
+
+func writeInProcessTestConfig(t *testing.T, dir string, resources map[string]any) {
+	t.Helper()
+	data, _ := json.Marshal(map[string]any{"resource": map[string]any{"genesyscloud_group": resources}})
+	writeTestFile(t, dir, "groups.tf.json", string(data))
+}
+
+func TestUnitInProcessApplySavedPlan(t *testing.T) {
+	const (
+		address  = "genesyscloud_group.example"
+		planFile = ".mrmo/plan.tfplan"
+	)
+	ctx := context.Background()
+	groups := fakeGroups{}
+	e, dir := newInProcessTestExecutor(t, map[string]*schema.Resource{"genesyscloud_group": groups.resource()})
+	writeInProcessTestConfig(t, dir, map[string]any{
+		"example": map[string]any{"name": "Example"},
+		"other":   map[string]any{"name": "Other"},
+	})
+
+	plan, diags := e.Plan(ctx, dir, executor.PlanOptions{Targets: []string{address}, PlanFile: planFile})
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if len(plan.ResourceChanges) != 1 || plan.ResourceChanges[0].Address != address || plan.ResourceChanges[0].Actions[0] != "create" {
+		t.Fatalf("expected a plan creating %s, got %+v", address, plan.ResourceChanges)
+	}
+	if len(groups) != 0 {
+		t.Fatalf("expected planning not to change anything, got %v", groups)
+	}
+
+	// only the changes of the plan are applied, even though the config has another resource
+	if diags = e.Apply(ctx, dir, executor.ApplyOptions{PlanFile: planFile}); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if len(groups) != 1 || groups["target-1"] != "Example" {
+		t.Fatalf("expected only %s to be created, got %v", address, groups)
+	}
+
+	// a saved destroy plan deletes the resource, although it is still in the config
+	if _, diags = e.Plan(ctx, dir, executor.PlanOptions{Targets: []string{address}, Destroy: true, PlanFile: planFile}); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if diags = e.Apply(ctx, dir, executor.ApplyOptions{PlanFile: planFile}); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if len(groups) != 0 {
+		t.Errorf("expected the group to be deleted, got %v", groups)
+	}
+	if resources, err := e.State(ctx, dir); err != nil || len(resources) != 0 {
+		t.Errorf("expected an empty state, got %v (%v)", resources, err)
+	}
+}
+
+func TestUnitInProcessApplyFlowArtifact(t *testing.T) {
+	ctx := context.Background()
+	flows := map[string]string{}
+	flowResource := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"filepath": {Type: schema.TypeString, Required: true},
+		},
+		// stands in for the provider, which uploads the file at filepath
+		CreateContext: func(_ context.Context, d *schema.ResourceData, _ any) diag.Diagnostics {
+			content, err := os.ReadFile(d.Get("filepath").(string))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			d.SetId("target-flow")
+			flows[d.Id()] = string(content)
+			return nil
+		},
+		ReadContext: func(context.Context, *schema.ResourceData, any) diag.Diagnostics {
+			return nil
+		},
+		DeleteContext: func(context.Context, *schema.ResourceData, any) diag.Diagnostics {
+			return nil
+		},
+	}
+	e, dir := newInProcessTestExecutor(t, map[string]*schema.Resource{flowResourceType: flowResource})
+
+	// the artifact is only in the config directory, not in the working directory of the process
+	const relativePath = "flows/inbound.yaml"
+	writeTestFile(t, dir, relativePath, "inboundCall:\n  name: Inbound\n")
+	data, _ := json.Marshal(map[string]any{"resource": map[string]any{flowResourceType: map[string]any{
+		"inbound": map[string]any{"filepath": relativePath},
+	}}})
+	writeTestFile(t, dir, "flows.tf.json", string(data))
+
+	if diags := e.Apply(ctx, dir, executor.ApplyOptions{}); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if flows["target-flow"] != "inboundCall:\n  name: Inbound\n" {
+		t.Errorf("expected the flow to be created from the artifact in the config directory, got %v", flows)
+	}
+}
+
+func TestUnitInProcessApplyTimeout(t *testing.T) {
+	groups := fakeGroups{}
+	slow := groups.resource()
+	slow.CreateContext = func(ctx context.Context, _ *schema.ResourceData, _ any) diag.Diagnostics {
+		// stands in for a provider call that only returns once its context is done
+		<-ctx.Done()
+		return diag.FromErr(ctx.Err())
+	}
+	e, dir := newInProcessTestExecutor(t, map[string]*schema.Resource{"genesyscloud_group": slow})
+	e.m.OrgManager.Executor.Timeouts.Apply = 100 * time.Millisecond
+	writeInProcessTestConfig(t, dir, map[string]any{
+		"a": map[string]any{"name": "A"},
+		"b": map[string]any{"name": "B"},
+	})
+
+	start := time.Now()
+	diags := e.Apply(context.Background(), dir, executor.ApplyOptions{})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the apply to stop at its timeout, took %s", elapsed)
+	}
+	if !executor.IsTimeout(diags) {
+		t.Fatalf("expected a timeout diagnostic, got %v", diags)
+	}
+	if resources, err := e.State(context.Background(), dir); err != nil || len(resources) != 0 {
+		t.Errorf("expected nothing to be applied after the timeout, got %v (%v)", resources, err)
+	}
+}
+
+func TestUnitInProcessWithTargetMetaUnknownDir(t *testing.T) {
+	e, _ := newInProcessTestExecutor(t, nil)
+
+	called := false
+	diags := e.withTargetMeta(t.TempDir(), func(any) diag.Diagnostics {
+		called = true
+		return nil
+	})
+	if !diags.HasError() {
+		t.Error("expected an error for a directory that belongs to no target org")
+	}
+	if called {
+		t.Error("expected nothing to run for a directory that belongs to no target org")
+	}
+}
@@ -0,0 +1,606 @@
+package mrmo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/tfexporter"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// layoutEntity keeps the config of each source entity in <source entity ID>.tf.json
+	layoutEntity = "entity"
+	// layoutResourceType keeps the configs of all source entities of a resource type in <resource type>.tf.json
+	layoutResourceType = "resourceType"
+
+	// layoutManifestFile lists the source entities in a config directory, and the file each of them is in
+	layoutManifestFile = "manifest.json"
+)
+
+// layoutManifest maps the files of a config directory to the source entities they hold
+type layoutManifest struct {
+	Layout   string        `json:"layout"`
+	Entities []layoutEntry `json:"entities"`
+}
+
+// layoutEntry is a source entity in a config directory
+type layoutEntry struct {
+	File           string `json:"file"`
+	SourceEntityId string `json:"sourceEntityId"`
+	ResourceType   string `json:"resourceType"`
+	Label          string `json:"label"`
+}
+
+// address is the address of the entity's resource in the config directory
+func (e layoutEntry) address() string {
+	return e.ResourceType + "." + e.Label
+}
+
+// configFileName returns the name of the file the config of the entity is kept in under the given layout
+func configFileName(layout string, entry layoutEntry) string {
+	if layout == layoutResourceType {
+		return entry.ResourceType + ".tf.json"
+	}
+	return entry.SourceEntityId + ".tf.json"
+}
+
+// MigrateLayout moves the configs in the config directory of a target org into the layout set in the credentials
+// file.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - credentialsFilePath: Path to the credentials file
+//   - orgId: The ID of the target org
+//
+// Returns:
+//   - diag.Diagnostics: Collection of diagnostic messages and errors encountered during the migration
+//
+// The org is snapshotted first, so the migration can be undone with RestoreSnapshot. Moving a resource between files
+// of a config directory does not change its address, so the migration needs no apply. A resource relabelled because
+// its label is taken in the file it moves to is moved to its new address in the state instead.
+func MigrateLayout(ctx context.Context, credentialsFilePath, orgId string) (diags diag.Diagnostics) {
+	m, target, err := newMaintenanceMrMo(credentialsFilePath, orgId)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	m.MessageId = "layout-" + m.OrgManager.Layout
+
+	fm := m.newFileManager(orgId)
+	diags = append(diags, fm.pull(ctx)...)
+	if diags.HasError() {
+		return diags
+	}
+	diags = append(diags, fm.verify()...)
+	if diags.HasError() {
+		return diags
+	}
+	if _, err = m.backupTarget(ctx, fm, target); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	iac, err := m.getExecutor()
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	log.Printf("Migrating '%s' to the %s layout", fm.targetConfigDir, fm.layoutName())
+	return append(diags, fm.migrateLayout(ctx, iac)...)
+}
+
+// find returns the entry of the source entity, or nil if it is not in the manifest
+func (l *layoutManifest) find(sourceEntityId string) *layoutEntry {
+	for i := range l.Entities {
+		if l.Entities[i].SourceEntityId == sourceEntityId {
+			return &l.Entities[i]
+		}
+	}
+	return nil
+}
+
+// set adds the entry to the manifest, replacing the previous entry of its source entity
+func (l *layoutManifest) set(entry layoutEntry) {
+	l.remove(entry.SourceEntityId)
+	l.Entities = append(l.Entities, entry)
+}
+
+// remove removes the entry of the source entity from the manifest
+func (l *layoutManifest) remove(sourceEntityId string) {
+	entities := l.Entities[:0]
+	for _, e := range l.Entities {
+		if e.SourceEntityId != sourceEntityId {
+			entities = append(entities, e)
+		}
+	}
+	l.Entities = entities
+}
+
+// layoutName returns the layout of the config directory, defaulting to the entity layout
+func (f *FileManager) layoutName() string {
+	if f.layout == "" {
+		return layoutEntity
+	}
+	return f.layout
+}
+
+// entry returns the manifest entry of the source entity being processed, in the file the layout keeps it in
+func (f *FileManager) entry() layoutEntry {
+	entry := layoutEntry{
+		SourceEntityId: f.sourceEntityId,
+		ResourceType:   f.resourceType,
+		Label:          f.resourceLabel,
+	}
+	entry.File = configFileName(f.layoutName(), entry)
+	return entry
+}
+
+// configExists returns true if the config directory holds a config for the source entity
+func (f *FileManager) configExists() bool {
+	if f.layoutName() != layoutResourceType {
+		return fileExists(f.targetConfigFile)
+	}
+	manifest, err := f.readManifest()
+	if err != nil {
+		log.Printf("Failed to verify if source entity '%s' is in '%s'. Returning false. Error: '%s'", f.sourceEntityId, f.targetConfigDir, err.Error())
+		return false
+	}
+	return manifest.find(f.sourceEntityId) != nil
+}
+
+// checkLayout refuses to use a config directory that is in a different layout than the configured one, since writing
+// an entity in the configured layout would duplicate the resource still in its old file
+func (f *FileManager) checkLayout() diag.Diagnostics {
+	layout := f.layoutName()
+	if layout != layoutEntity && layout != layoutResourceType {
+		return diag.Errorf("unknown config layout '%s'. Expected '%s' or '%s'", layout, layoutEntity, layoutResourceType)
+	}
+
+	manifest, err := f.readManifest()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	current := manifest.Layout
+	if current == "" {
+		// directories written before the manifest existed are in the entity layout
+		entities, err := f.discoverEntities()
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if len(entities) > 0 {
+			current = layoutEntity
+		}
+	}
+
+	if current != "" && current != layout {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Config directory of org '%s' is in the %s layout, but the %s layout is configured", f.targetOrgId, current, layout),
+			Detail:   fmt.Sprintf("Run 'go run . layout -org %s' to migrate '%s' to the %s layout.", f.targetOrgId, f.targetConfigDir, layout),
+		}}
+	}
+	return nil
+}
+
+// readManifest reads the layout manifest of the config directory. An empty manifest is returned if there is none.
+func (f *FileManager) readManifest() (*layoutManifest, error) {
+	manifestFile := filepath.Join(f.targetConfigDir, layoutManifestFile)
+	data, err := os.ReadFile(manifestFile)
+	if os.IsNotExist(err) {
+		return &layoutManifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file '%s'. Error: %w", manifestFile, err)
+	}
+
+	var manifest layoutManifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse file '%s'. Error: %w", manifestFile, err)
+	}
+	return &manifest, nil
+}
+
+// writeManifest writes the layout manifest of the config directory, or removes it if it lists no entities
+func (f *FileManager) writeManifest(ctx context.Context, manifest *layoutManifest) diag.Diagnostics {
+	manifestFile := filepath.Join(f.targetConfigDir, layoutManifestFile)
+	if len(manifest.Entities) == 0 {
+		if err := os.Remove(manifestFile); err != nil && !os.IsNotExist(err) {
+			return diag.Errorf("failed to delete file '%s'. Error: %s", manifestFile, err.Error())
+		}
+		return f.unpush(ctx, manifestFile)
+	}
+
+	sort.Slice(manifest.Entities, func(i, j int) bool {
+		a, b := manifest.Entities[i], manifest.Entities[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.SourceEntityId < b.SourceEntityId
+	})
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return diag.Errorf("failed to marshal layout manifest. Error: %s", err.Error())
+	}
+	if err = writeChecksummedFile(manifestFile, data); err != nil {
+		return diag.FromErr(err)
+	}
+	return f.push(ctx, manifestFile)
+}
+
+// recordInManifest records the file the config of the source entity was written to
+func (f *FileManager) recordInManifest(ctx context.Context) diag.Diagnostics {
+	manifest, err := f.readManifest()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	manifest.Layout = f.layoutName()
+	manifest.set(f.entry())
+	return f.writeManifest(ctx, manifest)
+}
+
+// removeFromManifest removes the source entity from the manifest
+func (f *FileManager) removeFromManifest(ctx context.Context) diag.Diagnostics {
+	manifest, err := f.readManifest()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if manifest.find(f.sourceEntityId) == nil {
+		return nil
+	}
+	manifest.remove(f.sourceEntityId)
+	return f.writeManifest(ctx, manifest)
+}
+
+// mergeIntoConfigFile writes the config of the source entity into the resource type's file, in place of its previous
+// config. The configs of other entities in the file are kept. If the label of the entity is taken by another resource
+// in the file, the entity is given a unique one (see mergeEntityBlocks), which is then recorded in the manifest.
+func (f *FileManager) mergeIntoConfigFile(ctx context.Context, resourceConfig map[string]any) diag.Diagnostics {
+	manifest, err := f.readManifest()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	config, err := readConfigFile(f.targetConfigFile)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// round trip through JSON, so that the blocks are of the same types as the ones read from the file
+	data, err := json.Marshal(resourceConfig)
+	if err != nil {
+		return diag.Errorf("failed to marshal config of source entity '%s'. Error: %s", f.sourceEntityId, err.Error())
+	}
+	blocks := make(map[string]any)
+	if err = json.Unmarshal(data, &blocks); err != nil {
+		return diag.Errorf("failed to parse config of source entity '%s'. Error: %s", f.sourceEntityId, err.Error())
+	}
+
+	entry := f.entry()
+	if previous := manifest.find(f.sourceEntityId); previous != nil {
+		extractEntityBlocks(config, *previous)
+		// keep a label made unique by an earlier collision, so the address does not change once the collision is gone
+		if previous.Label == uniqueLabel(entry) {
+			relabelEntityBlocks(blocks, entry, previous.Label)
+			entry.Label = previous.Label
+		}
+	}
+
+	if entry, err = mergeEntityBlocks(config, blocks, entry); err != nil {
+		return diag.FromErr(err)
+	}
+	f.resourceLabel = entry.Label
+	return f.writeConfigFile(ctx, f.targetConfigFile, config)
+}
+
+// removeFromConfigFile removes the config of the source entity from the file it is in, deleting the file if no other
+// config is left in it
+func (f *FileManager) removeFromConfigFile(ctx context.Context) diag.Diagnostics {
+	manifest, err := f.readManifest()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	entry := manifest.find(f.sourceEntityId)
+	if entry == nil {
+		return nil
+	}
+
+	filePath := filepath.Join(f.targetConfigDir, entry.File)
+	config, err := readConfigFile(filePath)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	log.Printf("Removing %s from file '%s'", entry.address(), filePath)
+	extractEntityBlocks(config, *entry)
+	return f.writeConfigFile(ctx, filePath, config)
+}
+
+// writeTfConfig is the exporter's writer for *.tf.json files. Every config file is written with it, whether it holds
+// one entity or many, so that files are formatted the same under every layout.
+var writeTfConfig = tfexporter.WriteConfigForMrMo
+
+// writeConfigFile writes config to the *.tf.json file at filePath, or deletes the file if config is empty. The config
+// is written to a temporary file first, so a crash never leaves a truncated config behind.
+func (f *FileManager) writeConfigFile(ctx context.Context, filePath string, config map[string]any) (diags diag.Diagnostics) {
+	if len(config) == 0 {
+		log.Printf("Deleting file '%s'", filePath)
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return diag.Errorf("failed to delete file '%s'. Error: %s", filePath, err.Error())
+		}
+		return f.unpush(ctx, filePath)
+	}
+
+	log.Printf("Writing file '%s'", filePath)
+	err := writeFileAtomic(filePath, true, func(tmpPath string) error {
+		diags = append(diags, writeTfConfig(config, tmpPath)...)
+		if diags.HasError() {
+			return errors.New("the exporter failed to write the config")
+		}
+		return nil
+	})
+	if err != nil {
+		if !diags.HasError() {
+			diags = append(diags, diag.FromErr(err)...)
+		}
+		return diags
+	}
+	return append(diags, f.push(ctx, filePath)...)
+}
+
+// discoverEntities returns the source entities in the config directory: the ones in the manifest, along with the ones
+// in <source entity ID>.tf.json files written before the manifest existed. These are recognized by the output block
+// holding the target entity ID.
+func (f *FileManager) discoverEntities() ([]layoutEntry, error) {
+	manifest, err := f.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	entities := manifest.Entities
+
+	files, err := filepath.Glob(filepath.Join(f.targetConfigDir, "*.tf.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		sourceEntityId := strings.TrimSuffix(filepath.Base(file), ".tf.json")
+		if manifest.find(sourceEntityId) != nil {
+			continue
+		}
+		config, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if outputs, _ := config["output"].(map[string]any); outputs[buildOutputKey(sourceEntityId)] == nil {
+			continue
+		}
+
+		var addresses []layoutEntry
+		resources, _ := config["resource"].(map[string]any)
+		for resourceType, ofType := range resources {
+			labels, _ := ofType.(map[string]any)
+			for label := range labels {
+				addresses = append(addresses, layoutEntry{File: filepath.Base(file), SourceEntityId: sourceEntityId, ResourceType: resourceType, Label: label})
+			}
+		}
+		if len(addresses) != 1 {
+			return nil, fmt.Errorf("expected file '%s' to hold the resource of source entity '%s' only, found %d resources", file, sourceEntityId, len(addresses))
+		}
+		entities = append(entities, addresses[0])
+	}
+	return entities, nil
+}
+
+// migrateLayout moves the config of every source entity in the config directory into the file of the configured
+// layout, and rewrites the manifest. Resources that do not belong to a source entity, e.g. in hand written files, are
+// left in place. An entity relabelled because its label is taken in the file it moves to is moved to its new address
+// in the state with iac.
+func (f *FileManager) migrateLayout(ctx context.Context, iac executor.Executor) (diags diag.Diagnostics) {
+	entities, err := f.discoverEntities()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	layout := f.layoutName()
+	for i, entry := range entities {
+		file := configFileName(layout, entry)
+		if entry.File == file {
+			continue
+		}
+		log.Printf("Moving %s of source entity '%s' from '%s' to '%s'", entry.address(), entry.SourceEntityId, entry.File, file)
+
+		fromPath := filepath.Join(f.targetConfigDir, entry.File)
+		from, err := readConfigFile(fromPath)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		blocks := extractEntityBlocks(from, entry)
+
+		toPath := filepath.Join(f.targetConfigDir, file)
+		to, err := readConfigFile(toPath)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		merged, err := mergeEntityBlocks(to, blocks, entry)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		if merged.address() != entry.address() {
+			diags = append(diags, moveStateAddress(ctx, iac, f.targetConfigDir, entry.address(), merged.address())...)
+			if diags.HasError() {
+				return diags
+			}
+		}
+
+		// write the destination first, so an interruption leaves the entity in both files rather than in neither
+		diags = append(diags, f.writeConfigFile(ctx, toPath, to)...)
+		if diags.HasError() {
+			return diags
+		}
+		diags = append(diags, f.writeConfigFile(ctx, fromPath, from)...)
+		if diags.HasError() {
+			return diags
+		}
+		entities[i] = merged
+		entities[i].File = file
+	}
+
+	return append(diags, f.writeManifest(ctx, &layoutManifest{Layout: layout, Entities: entities})...)
+}
+
+// readConfigFile reads the *.tf.json file at filePath. An empty config is returned if the file does not exist.
+func readConfigFile(filePath string) (map[string]any, error) {
+	config := make(map[string]any)
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file '%s'. Error: %w", filePath, err)
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse file '%s'. Error: %w", filePath, err)
+	}
+	return config, nil
+}
+
+// extractEntityBlocks removes the resource, output and import blocks of the entity from config, along with any blocks
+// left empty, and returns them as a config of their own
+func extractEntityBlocks(config map[string]any, entry layoutEntry) map[string]any {
+	blocks := make(map[string]any)
+
+	if resources, ok := config["resource"].(map[string]any); ok {
+		if ofType, ok := resources[entry.ResourceType].(map[string]any); ok {
+			if resource, ok := ofType[entry.Label]; ok {
+				blocks["resource"] = map[string]any{entry.ResourceType: map[string]any{entry.Label: resource}}
+				delete(ofType, entry.Label)
+			}
+			if len(ofType) == 0 {
+				delete(resources, entry.ResourceType)
+			}
+		}
+	}
+
+	if outputs, ok := config["output"].(map[string]any); ok {
+		outputKey := buildOutputKey(entry.SourceEntityId)
+		if output, ok := outputs[outputKey]; ok {
+			blocks["output"] = map[string]any{outputKey: output}
+			delete(outputs, outputKey)
+		}
+	}
+
+	if imports, ok := config["import"].([]any); ok {
+		var kept, moved []any
+		for _, i := range imports {
+			if block, ok := i.(map[string]any); ok && block["to"] == entry.address() {
+				moved = append(moved, i)
+				continue
+			}
+			kept = append(kept, i)
+		}
+		config["import"] = kept
+		if len(moved) > 0 {
+			blocks["import"] = moved
+		}
+	}
+
+	for key, block := range config {
+		switch typed := block.(type) {
+		case map[string]any:
+			if len(typed) == 0 {
+				delete(config, key)
+			}
+		case []any:
+			if len(typed) == 0 {
+				delete(config, key)
+			}
+		}
+	}
+	return blocks
+}
+
+// mergeEntityBlocks merges the blocks of the entity into config, and returns the entry of the entity in it. If another
+// resource in config is already labelled like the entity, the entity is relabelled with uniqueLabel first, since the
+// file would otherwise declare its address twice. Returns an error if the unique label is taken as well.
+func mergeEntityBlocks(config, blocks map[string]any, entry layoutEntry) (layoutEntry, error) {
+	if labelTaken(config, entry) {
+		label := uniqueLabel(entry)
+		log.Printf("%s is taken, labelling source entity '%s' as '%s'", entry.address(), entry.SourceEntityId, label)
+		relabelEntityBlocks(blocks, entry, label)
+		entry.Label = label
+		if labelTaken(config, entry) {
+			return entry, fmt.Errorf("cannot add source entity '%s': another resource in the config directory is already labelled %s", entry.SourceEntityId, entry.address())
+		}
+	}
+	mergeBlocks(config, blocks)
+	return entry, nil
+}
+
+// labelTaken returns true if config holds a resource at the entity's address
+func labelTaken(config map[string]any, entry layoutEntry) bool {
+	resources, _ := config["resource"].(map[string]any)
+	ofType, _ := resources[entry.ResourceType].(map[string]any)
+	return ofType[entry.Label] != nil
+}
+
+// uniqueLabel returns the label of the entity suffixed with its source entity ID, which no other source entity's
+// resource can be labelled with
+func uniqueLabel(entry layoutEntry) string {
+	return entry.Label + "_" + sanitizeString(entry.SourceEntityId)
+}
+
+// relabelEntityBlocks renames the resource of the entity in blocks to label, and points its outputs and import blocks
+// at the new address
+func relabelEntityBlocks(blocks map[string]any, entry layoutEntry, label string) {
+	relabelled := entry
+	relabelled.Label = label
+
+	if resources, ok := blocks["resource"].(map[string]any); ok {
+		if ofType, ok := resources[entry.ResourceType].(map[string]any); ok {
+			if resource, ok := ofType[entry.Label]; ok {
+				delete(ofType, entry.Label)
+				ofType[label] = resource
+			}
+		}
+	}
+
+	if outputs, ok := blocks["output"].(map[string]any); ok {
+		for _, o := range outputs {
+			if output, ok := o.(map[string]any); ok {
+				if value, ok := output["value"].(string); ok {
+					output["value"] = strings.ReplaceAll(value, "${"+entry.address()+".", "${"+relabelled.address()+".")
+				}
+			}
+		}
+	}
+
+	if imports, ok := blocks["import"].([]any); ok {
+		for _, i := range imports {
+			if block, ok := i.(map[string]any); ok && block["to"] == entry.address() {
+				block["to"] = relabelled.address()
+			}
+		}
+	}
+}
+
+// mergeBlocks merges src into dst. Nested blocks are merged, lists are appended and any other value of src replaces
+// the one in dst.
+func mergeBlocks(dst, src map[string]any) {
+	for key, value := range src {
+		switch typed := value.(type) {
+		case map[string]any:
+			if existing, ok := dst[key].(map[string]any); ok {
+				mergeBlocks(existing, typed)
+				continue
+			}
+		case []any:
+			if existing, ok := dst[key].([]any); ok {
+				dst[key] = append(existing, typed...)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,284 @@
+package mrmo
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func layoutTestConfig(sourceEntityId, label string) util.JsonMap {
+	config := util.JsonMap{
+		"resource": map[string]any{
+			testResourceType: map[string]any{
+				label: map[string]any{"name": label},
+			},
+		},
+	}
+	return appendOutputBlockToConfig(config, testResourceType+"."+label, sourceEntityId)
+}
+
+func layoutTestLabels(config map[string]any) map[string]bool {
+	labels := make(map[string]bool)
+	resources, _ := config["resource"].(map[string]any)
+	ofType, _ := resources[testResourceType].(map[string]any)
+	for label := range ofType {
+		labels[label] = true
+	}
+	return labels
+}
+
+func TestUnitResourceTypeLayout(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMrMo(t)
+	useJsonConfigWriter(t)
+
+	m.OrgManager.Layout = layoutResourceType
+	for _, entity := range [][2]string{{"source-1", "group_a"}, {"source-2", "group_b"}} {
+		fm := newTestFileManager(m, entity[0], entity[1])
+		if diags := fm.updateTargetTfConfig(ctx, layoutTestConfig(entity[0], entity[1]), false); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+	}
+
+	fm := newTestFileManager(m, "source-1", "group_a_renamed")
+	typeFile := filepath.Join(fm.targetConfigDir, testResourceType+".tf.json")
+	if fm.targetConfigFile != typeFile {
+		t.Fatalf("expected the config file to be '%s', got '%s'", typeFile, fm.targetConfigFile)
+	}
+	if !fm.exists {
+		t.Error("expected source-1 to exist in the config directory")
+	}
+
+	// a renamed entity replaces its previous resource
+	if diags := fm.updateTargetTfConfig(ctx, layoutTestConfig("source-1", "group_a_renamed"), false); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	config := readTestConfigFile(t, typeFile)
+	if labels := layoutTestLabels(config); len(labels) != 2 || !labels["group_a_renamed"] || !labels["group_b"] {
+		t.Errorf("expected resources group_a_renamed and group_b, got %v", labels)
+	}
+	if outputs, _ := config["output"].(map[string]any); len(outputs) != 2 {
+		t.Errorf("expected an output for each source entity, got %v", outputs)
+	}
+
+	manifest, err := fm.readManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Layout != layoutResourceType || len(manifest.Entities) != 2 {
+		t.Fatalf("expected both entities in the %s layout manifest, got %+v", layoutResourceType, manifest)
+	}
+	if entry := manifest.find("source-1"); entry == nil || entry.Label != "group_a_renamed" || entry.File != filepath.Base(typeFile) {
+		t.Errorf("unexpected manifest entry for source-1: %+v", entry)
+	}
+
+	// deleting an entity keeps the other entities of the type
+	fm = newTestFileManager(m, "source-2", "")
+	if diags := fm.updateTargetTfConfig(ctx, nil, true); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if labels := layoutTestLabels(readTestConfigFile(t, typeFile)); len(labels) != 1 || !labels["group_a_renamed"] {
+		t.Errorf("expected only group_a_renamed to be left, got %v", labels)
+	}
+
+	fm = newTestFileManager(m, "source-1", "")
+	if diags := fm.updateTargetTfConfig(ctx, nil, true); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if fileExists(typeFile) || fileExists(filepath.Join(fm.targetConfigDir, layoutManifestFile)) {
+		t.Error("expected the file and manifest to be removed along with the last entity")
+	}
+}
+
+func TestUnitMigrateLayout(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMrMo(t)
+	useJsonConfigWriter(t)
+
+	// entity files written before the manifest existed, next to a hand written file of the same resource type
+	m.OrgManager.Layout = layoutEntity
+	dir := newTestFileManager(m, "", "").targetConfigDir
+	for _, entity := range [][2]string{{"source-1", "group_a"}, {"source-2", "group_b"}} {
+		data, _ := json.Marshal(layoutTestConfig(entity[0], entity[1]))
+		if err := writeFile(filepath.Join(dir, entity[0]+".tf.json"), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handWritten := `{"resource":{"` + testResourceType + `":{"group_c":{"name":"group_c"}}}}`
+	if err := writeFile(filepath.Join(dir, testResourceType+".tf.json"), []byte(handWritten)); err != nil {
+		t.Fatal(err)
+	}
+
+	m.OrgManager.Layout = layoutResourceType
+	fm := newTestFileManager(m, "", "")
+	if diags := fm.checkLayout(); !diags.HasError() {
+		t.Error("expected the entity layout directory to be refused under the resourceType layout")
+	}
+	if diags := fm.migrateLayout(ctx, m.Executor); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if diags := fm.checkLayout(); diags.HasError() {
+		t.Errorf("unexpected error after migration: %v", diags)
+	}
+
+	typeFile := filepath.Join(dir, testResourceType+".tf.json")
+	if labels := layoutTestLabels(readTestConfigFile(t, typeFile)); len(labels) != 3 {
+		t.Errorf("expected group_a, group_b and group_c in '%s', got %v", typeFile, labels)
+	}
+	for _, id := range []string{"source-1", "source-2"} {
+		if fileExists(filepath.Join(dir, id+".tf.json")) {
+			t.Errorf("expected the file of %s to be removed", id)
+		}
+	}
+
+	// and back again, leaving the hand written resource in place
+	m.OrgManager.Layout = layoutEntity
+	fm = newTestFileManager(m, "", "")
+	if diags := fm.migrateLayout(ctx, m.Executor); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if labels := layoutTestLabels(readTestConfigFile(t, typeFile)); len(labels) != 1 || !labels["group_c"] {
+		t.Errorf("expected only group_c to be left in '%s', got %v", typeFile, labels)
+	}
+	config := readTestConfigFile(t, filepath.Join(dir, "source-1.tf.json"))
+	if labels := layoutTestLabels(config); len(labels) != 1 || !labels["group_a"] {
+		t.Errorf("expected group_a in the file of source-1, got %v", labels)
+	}
+	if outputs, _ := config["output"].(map[string]any); outputs[buildOutputKey("source-1")] == nil {
+		t.Error("expected the output of source-1 to move along with its resource")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "source-1.tf.json"+checksumSuffix)); err != nil {
+		t.Errorf("expected the migrated file to have a checksum: %v", err)
+	}
+}
+
+func TestUnitResourceTypeLayoutLabelCollision(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMrMo(t)
+	useJsonConfigWriter(t)
+
+	m.OrgManager.Layout = layoutResourceType
+	for _, id := range []string{"source-1", "source-2", "source-2"} {
+		fm := newTestFileManager(m, id, "group")
+		if diags := fm.updateTargetTfConfig(ctx, layoutTestConfig(id, "group"), false); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+	}
+
+	fm := newTestFileManager(m, "source-2", "group")
+	config := readTestConfigFile(t, fm.targetConfigFile)
+	if labels := layoutTestLabels(config); len(labels) != 2 || !labels["group"] || !labels["group_source-2"] {
+		t.Errorf("expected resources group and group_source-2, got %v", labels)
+	}
+	outputs, _ := config["output"].(map[string]any)
+	output, _ := outputs[buildOutputKey("source-2")].(map[string]any)
+	if expected := "${" + testResourceType + ".group_source-2.id}"; output["value"] != expected {
+		t.Errorf("expected the output of source-2 to be '%s', got %v", expected, output["value"])
+	}
+
+	manifest, err := fm.readManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := manifest.find("source-2"); entry == nil || entry.Label != "group_source-2" {
+		t.Errorf("expected the manifest to record the unique label of source-2, got %+v", entry)
+	}
+
+	// the unique label is kept once the collision is gone, so the address of source-2 does not change
+	if diags := newTestFileManager(m, "source-1", "").updateTargetTfConfig(ctx, nil, true); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if diags := fm.updateTargetTfConfig(ctx, layoutTestConfig("source-2", "group"), false); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if labels := layoutTestLabels(readTestConfigFile(t, fm.targetConfigFile)); len(labels) != 1 || !labels["group_source-2"] {
+		t.Errorf("expected only group_source-2 to be left, got %v", labels)
+	}
+	if address := fm.entry().address(); address != testResourceType+".group_source-2" {
+		t.Errorf("expected source-2 to be applied at its unique address, got '%s'", address)
+	}
+}
+
+func TestUnitMigrateLayoutLabelCollision(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMrMo(t)
+	useJsonConfigWriter(t)
+	fake := &fakeExecutor{state: []executor.StateResource{
+		{Address: testResourceType + ".group", Type: testResourceType, Name: "group", Values: map[string]any{"id": "target-1"}},
+	}}
+	m.Executor = fake
+
+	// an entity file, and a hand written file of the same resource type with a resource of the same label
+	m.OrgManager.Layout = layoutEntity
+	dir := newTestFileManager(m, "", "").targetConfigDir
+	data, _ := json.Marshal(addImportBlock(layoutTestConfig("source-1", "group"), testResourceType+".group", "target-1"))
+	if err := writeFile(filepath.Join(dir, "source-1.tf.json"), data); err != nil {
+		t.Fatal(err)
+	}
+	handWritten := `{"resource":{"` + testResourceType + `":{"group":{"name":"group"}}}}`
+	if err := writeFile(filepath.Join(dir, testResourceType+".tf.json"), []byte(handWritten)); err != nil {
+		t.Fatal(err)
+	}
+
+	m.OrgManager.Layout = layoutResourceType
+	fm := newTestFileManager(m, "", "")
+	if diags := fm.migrateLayout(ctx, m.Executor); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	address := testResourceType + ".group_source-1"
+	config := readTestConfigFile(t, filepath.Join(dir, testResourceType+".tf.json"))
+	if labels := layoutTestLabels(config); len(labels) != 2 || !labels["group"] || !labels["group_source-1"] {
+		t.Errorf("expected resources group and group_source-1, got %v", labels)
+	}
+	outputs, _ := config["output"].(map[string]any)
+	if output, _ := outputs[buildOutputKey("source-1")].(map[string]any); output["value"] != "${"+address+".id}" {
+		t.Errorf("expected the output of source-1 to refer to %s, got %v", address, output["value"])
+	}
+	if importAddress, err := findImportAddress(dir, "target-1"); err != nil || importAddress != address {
+		t.Errorf("expected the import block of source-1 to be to %s, got '%s' (%v)", address, importAddress, err)
+	}
+	if expected := "state mv " + testResourceType + ".group " + address; !slices.Contains(fake.commands, expected) {
+		t.Errorf("expected '%s' to be run, got %v", expected, fake.commands)
+	}
+
+	manifest, err := fm.readManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := manifest.find("source-1"); entry == nil || entry.address() != address {
+		t.Errorf("expected the manifest to record the unique label of source-1, got %+v", entry)
+	}
+}
+
+func TestUnitEntityLayout(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMrMo(t)
+	useJsonConfigWriter(t)
+
+	m.OrgManager.Layout = layoutEntity
+	fm := newTestFileManager(m, "source-1", "group_a")
+	entityFile := filepath.Join(fm.targetConfigDir, "source-1.tf.json")
+	if fm.targetConfigFile != entityFile {
+		t.Fatalf("expected the config file to be '%s', got '%s'", entityFile, fm.targetConfigFile)
+	}
+	if diags := fm.updateTargetTfConfig(ctx, layoutTestConfig("source-1", "group_a"), false); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if labels := layoutTestLabels(readTestConfigFile(t, entityFile)); len(labels) != 1 || !labels["group_a"] {
+		t.Errorf("expected group_a in the file of source-1, got %v", labels)
+	}
+
+	manifest, err := fm.readManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := manifest.find("source-1"); entry == nil || entry.File != "source-1.tf.json" {
+		t.Errorf("expected the manifest to record the file of source-1, got %+v", entry)
+	}
+}
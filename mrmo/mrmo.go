@@ -2,10 +2,15 @@ package mrmo
 
 import (
 	"context"
+	"fmt"
 	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
 	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/google/uuid"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider"
 	resourceExporter "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
 	"log"
@@ -19,6 +24,34 @@ type MrMo struct {
 	ProviderMeta   any
 	OrgManager     *orgManager.OrgManager
 	Exporter       *resourceExporter.ResourceExporter
+	// Executor is created on first use by getExecutor, since creating it requires the IaC binary
+	Executor    executor.Executor
+	ConfigStore configStore.ConfigStore
+
+	// MessageId identifies the message being processed. Backups taken before each apply are keyed by it.
+	MessageId string
+	// IsDelete is true when the message deletes the source entity
+	IsDelete bool
+
+	// files referenced by the exported config, collected after export
+	Artifacts []artifact
+
+	// ScanForUnlistedGuids enables a scan of the whole resource block for GUIDs that are not declared in the exporter's RefAttrs
+	ScanForUnlistedGuids bool
+
+	// StrictMode turns unresolved references into errors and refuses to write target configs containing source org GUIDs
+	StrictMode bool
+
+	// NameMatching enables looking up referenced entities in the target org by name when they have no mapping
+	NameMatching bool
+
+	// entities of each referenced type, keyed by org ID and then resource type. Populated lazily during name matching.
+	entityCache map[string]map[string]resourceExporter.ResourceIDMetaMap
+	// exported configs of every entity of a type, keyed by org ID, resource type and entity ID. Populated lazily, one
+	// export per org and type, during name matching and bootstrapping.
+	entityConfigCache map[string]map[string]map[string]util.JsonMap
+	// ProviderMeta of each target org, keyed by org ID, so that each org is authenticated once per instance
+	orgProviderMetas map[string]*provider.ProviderMeta
 
 	// determined after export
 	ResourcePath  string
@@ -26,9 +59,22 @@ type MrMo struct {
 }
 
 type Message struct {
+	// Id identifies the message in backups. A random ID is generated if it is empty.
+	Id string
+
 	ResourceType string
 	EntityId     string
 	IsDelete     bool
+
+	// ScanForUnlistedGuids enables resolution of GUID-shaped values that are not declared as references by the exporter
+	ScanForUnlistedGuids bool
+
+	// DisableStrictMode downgrades unresolved references to warnings. Strict mode is on by default.
+	DisableStrictMode bool
+
+	// EnableNameMatching makes Mr Mo look up unmapped references in the target org by name, and record the mappings it
+	// finds. Name matching is off by default.
+	EnableNameMatching bool
 }
 
 // ProcessMessage handles the processing of resource management operations based on incoming messages.
@@ -40,6 +86,9 @@ type Message struct {
 //   - ResourceType: The type of resource being processed
 //   - EntityId: The identifier of the source entity to process
 //   - IsDelete: Flag indicating if this is a delete operation
+//   - ScanForUnlistedGuids: Flag enabling resolution of GUIDs not declared in the exporter's RefAttrs
+//   - DisableStrictMode: Flag allowing unresolved references to be written to target configs
+//   - EnableNameMatching: Flag enabling the by-name lookup of references that have no mapping
 //   - om: OrgManager instance for handling organization-specific operations
 //
 // Returns:
@@ -48,10 +97,12 @@ type Message struct {
 // The function performs the following sequence:
 //  1. Initializes a new MrMo instance for the specified resource
 //  2. For delete operations:
-//     * Directly applies deletion across target organizations
+//     * Destroys exactly the entity tracked for the source entity in each target organization
 //  3. For create/update operations:
 //     * Exports the current resource configuration
 //     * Parses the resource path from the configuration
+//     * Applies the exporter's custom attribute resolvers to the configuration
+//     * Collects the files referenced by the configuration (audio prompts, scripts, flow YAML, etc.)
 //     * Appends necessary output blocks to the configuration (these are used to retrieve the target resource ID after apply)
 //     * Applies the configuration across target organizations
 func ProcessMessage(ctx context.Context, message Message, credentialsFilePath string) (diags diag.Diagnostics) {
@@ -60,8 +111,17 @@ func ProcessMessage(ctx context.Context, message Message, credentialsFilePath st
 		return diag.FromErr(err)
 	}
 
+	mrMo.MessageId = message.Id
+	if mrMo.MessageId == "" {
+		mrMo.MessageId = uuid.NewString()
+	}
+	mrMo.IsDelete = message.IsDelete
+	mrMo.ScanForUnlistedGuids = message.ScanForUnlistedGuids
+	mrMo.StrictMode = !message.DisableStrictMode
+	mrMo.NameMatching = message.EnableNameMatching
+
 	if message.IsDelete {
-		return mrMo.applyResourceConfigToTargetOrgs(nil, true)
+		return mrMo.applyResourceConfigToTargetOrgs(ctx, nil, true)
 	}
 
 	resourceConfig, exportDiags := mrMo.exportConfig(ctx, message.EntityId, message.ResourceType)
@@ -78,9 +138,18 @@ func ProcessMessage(ctx context.Context, message Message, credentialsFilePath st
 	mrMo.ResourceLabel = resourceLabel
 	mrMo.ResourcePath = message.ResourceType + "." + resourceLabel
 
+	if err = mrMo.applyCustomAttributeResolvers(resourceConfig); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	mrMo.Artifacts, err = mrMo.collectArtifacts(resourceConfig)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
 	resourceConfig = appendOutputBlockToConfig(resourceConfig, mrMo.ResourcePath, message.EntityId)
 
-	diags = append(diags, mrMo.applyResourceConfigToTargetOrgs(resourceConfig, false)...)
+	diags = append(diags, mrMo.applyResourceConfigToTargetOrgs(ctx, resourceConfig, false)...)
 	return diags
 }
 
@@ -88,6 +157,7 @@ func ProcessMessage(ctx context.Context, message Message, credentialsFilePath st
 // handling credential management and GUID resolution for each target.
 //
 // Parameters:
+//   - ctx: Context for the operation
 //   - resourceConfig: JsonMap containing the exported configuration to be applied
 //   - delete: Boolean flag indicating if this is a delete operation
 //
@@ -97,15 +167,28 @@ func ProcessMessage(ctx context.Context, message Message, credentialsFilePath st
 // The function performs the following operations for each target organization:
 //  1. Preserves original client credentials and restores them upon completion (the original client credentials are
 //     restored via deferred function regardless of success or failure.)
-//  2. Sets target organization-specific credentials
-//  3. Resolves GUIDs in the resource configuration to match the target organization
-//  4. Updates the Terraform configuration file in S3 for the target organization
-//  5. Runs a targeted tofu apply
-//  6. Updates the global mapping table according (add new mapping for create, delete for delete, etc.)
+//  2. Sets target organization-specific credentials, generates its provider config, makes sure its state is stored
+//     in the configured backend and backs up its state, configs and mappings (see backupTarget)
+//  3. Resolves GUIDs in the resource configuration to match the target organization (optionally including GUIDs that
+//     are not declared in the exporter's RefAttrs)
+//  4. In strict mode, verifies that no source org GUIDs remain in the resolved configuration
+//  5. Places the files referenced by the resource config, such as audio prompts and architect flow YAML
+//  6. On the first apply of the source entity, adopts a matching entity that already exists in the target
+//     organization with an import block
+//  7. Updates the Terraform configuration file in S3 for the target organization
+//  8. Runs a targeted apply with the configured executor (tofu by default)
+//  9. Updates the global mapping table with the ID of the target entity
+//
+// For delete operations, steps 3 to 9 are replaced by a targeted destroy of the entity tracked for the source entity
+// (see deleteFromTargetOrg).
 //
 // If any operation fails for a target organization, the function returns immediately with error diagnostics.
 // The original client credentials are restored via deferred function regardless of success or failure.
-func (m *MrMo) applyResourceConfigToTargetOrgs(resourceConfig util.JsonMap, delete bool) (diags diag.Diagnostics) {
+func (m *MrMo) applyResourceConfigToTargetOrgs(ctx context.Context, resourceConfig util.JsonMap, delete bool) (diags diag.Diagnostics) {
+	if _, err := m.getExecutor(); err != nil {
+		return diag.FromErr(err)
+	}
+
 	originalClientId, originalClientSecret, originalRegion := orgManager.GetClientCredsEnvVars()
 	defer func() {
 		// restore client cred env vars
@@ -115,7 +198,17 @@ func (m *MrMo) applyResourceConfigToTargetOrgs(resourceConfig util.JsonMap, dele
 		}
 	}()
 
+	var sourceReferences []reference
+	if !delete {
+		sourceReferences = m.extractReferencesUsingExporterRefAttrs(resourceConfig)
+	}
+
 	for _, target := range m.OrgManager.Targets {
+		// Don't start on another target once the message is cancelled, e.g. on shutdown
+		if err := ctx.Err(); err != nil {
+			return append(diags, diag.Errorf("stopped before processing org '%s' (%s): %s", target.Name, target.OrgId, err.Error())...)
+		}
+
 		// Set target org client credentials
 		err := target.SetTargetOrgCredentials()
 		if err != nil {
@@ -129,29 +222,105 @@ func (m *MrMo) applyResourceConfigToTargetOrgs(resourceConfig util.JsonMap, dele
 		}
 		// Resolve GUIDs in resourceConfig to target org GUIDs using the mapping table
 		if !delete {
-			resourceConfigCopy, err = m.resolveResourceConfigDependencies(resourceConfigCopy, target)
-			if err != nil {
-				return diag.FromErr(err)
+			var resolveDiags diag.Diagnostics
+			resourceConfigCopy, resolveDiags = m.resolveResourceConfigDependencies(ctx, resourceConfigCopy, target)
+			diags = append(diags, resolveDiags...)
+			if diags.HasError() {
+				return diags
+			}
+
+			if m.ScanForUnlistedGuids {
+				var scanDiags diag.Diagnostics
+				resourceConfigCopy, scanDiags = m.resolveUnlistedGuids(resourceConfigCopy, target)
+				diags = append(diags, scanDiags...)
+				if diags.HasError() {
+					return diags
+				}
+			}
+		}
+
+		fm := m.newFileManager(target.OrgId)
+		diags = append(diags, fm.pull(ctx)...)
+		if diags.HasError() {
+			return diags
+		}
+		diags = append(diags, fm.verify()...)
+		diags = append(diags, fm.checkLayout()...)
+		if diags.HasError() {
+			return diags
+		}
+
+		// Keep the provider config in line with the exporter, then make sure the target org's state is in the
+		// configured backend before touching it
+		diags = append(diags, fm.writeProviderConfig(ctx, target, m.OrgManager.ProviderVersion)...)
+		if diags.HasError() {
+			return diags
+		}
+		diags = append(diags, m.ensureStateBackend(ctx, fm, target)...)
+		if diags.HasError() {
+			return diags
+		}
+
+		// Snapshot the target's state and configs, so that they can be restored if this message breaks them
+		if _, err = m.backupTarget(ctx, fm, target); err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+
+		if delete {
+			diags = append(diags, m.deleteFromTargetOrg(ctx, fm, target)...)
+			if diags.HasError() {
+				return diags
+			}
+			continue
+		}
+
+		// Place the files the resource config references, e.g. audio prompts and flow YAML
+		artifacts, artifactDiags := m.prepareArtifactsForTarget(target)
+		diags = append(diags, artifactDiags...)
+		if diags.HasError() {
+			return diags
+		}
+		diags = append(diags, fm.placeArtifacts(ctx, artifacts)...)
+		if diags.HasError() {
+			return diags
+		}
+
+		// Refuse to write a config that still points at source org entities
+		if m.StrictMode {
+			diags = append(diags, m.verifyNoSourceGuids(resourceConfigCopy, sourceReferences, target)...)
+			if diags.HasError() {
+				return diags
 			}
 		}
 
-		fm := newFileManager(target.OrgId, m.Id)
+		// Adopt a matching entity that already exists in the target org rather than creating a duplicate
+		var adoptDiags diag.Diagnostics
+		resourceConfigCopy, adoptDiags = m.adoptExistingEntity(ctx, resourceConfigCopy, fm, target)
+		diags = append(diags, adoptDiags...)
+		if diags.HasError() {
+			return diags
+		}
 
 		// Update the tf file in s3 for the current target org
-		diags = append(diags, fm.updateTargetTfConfig(resourceConfigCopy, delete)...)
+		diags = append(diags, fm.updateTargetTfConfig(ctx, resourceConfigCopy, false)...)
 		if diags.HasError() {
 			return diags
 		}
 
-		// Run targeted apply
-		targetResourceId, applyDiags := applyWithOpenTofu(fm.targetConfigDir, m.Id, m.ResourcePath, delete)
+		// Run targeted apply, at the address the config was written to. In the resourceType layout, it differs from
+		// m.ResourcePath if the label was taken by another resource of the file.
+		resourcePath := fm.entry().address()
+		targetResourceId, applyDiags := applyWithExecutor(ctx, m.Executor, fm.targetConfigDir, m.Id, resourcePath)
 		diags = append(diags, applyDiags...)
+		if executor.IsTimeout(applyDiags) {
+			diags = append(diags, timeoutDiagnostic(resourcePath, target))
+		}
 		if diags.HasError() {
 			return diags
 		}
 
 		// Update mapping table accordingly
-		err = m.updateDynamoTable(m.ResourceType, target.OrgId, targetResourceId, delete)
+		err = mockDynamo.UpdateItem(m.ResourceType, m.Id, target.OrgId, targetResourceId)
 		if err != nil {
 			diags = append(diags, diag.FromErr(err)...)
 			return diags
@@ -160,9 +329,67 @@ func (m *MrMo) applyResourceConfigToTargetOrgs(resourceConfig util.JsonMap, dele
 	return
 }
 
-func (m *MrMo) updateDynamoTable(resourceType, targetOrgId, targetResourceId string, delete bool) (err error) {
-	if !delete {
-		return mockDynamo.UpdateItem(resourceType, m.Id, targetOrgId, targetResourceId)
+// ensureStateBackend writes the state backend config for the target org and, if it changed, migrates the existing state
+// (e.g. a local terraform.tfstate) into the new backend
+func (m *MrMo) ensureStateBackend(ctx context.Context, fm *FileManager, target orgManager.OrgData) (diags diag.Diagnostics) {
+	backendConfig, err := executor.RenderStateBackend(m.OrgManager.StateBackend, target.OrgId)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	changed, writeDiags := fm.writeBackendConfig(ctx, backendConfig)
+	diags = append(diags, writeDiags...)
+	if diags.HasError() || !changed {
+		return diags
 	}
-	return mockDynamo.DeleteItem(m.Id)
+
+	log.Printf("State backend for org '%s' changed. Migrating state", target.OrgId)
+	return append(diags, m.Executor.MigrateState(ctx, fm.targetConfigDir)...)
+}
+
+// deleteFromTargetOrg destroys the entity tracked for the source entity in the target org, and only that entity.
+//
+// Parameters:
+//   - fm: FileManager for the target org
+//   - target: The target org data
+//
+// Returns:
+//   - diag.Diagnostics: Collection of diagnostic messages and errors encountered during the delete
+//
+// The target entity is found using the mapping table, and its address is resolved from the target org's state. A
+// destroy plan targeting that address is created and shown, and is only applied if it affects no other resource. Once
+// the entity is destroyed, its config file and artifacts are removed and its mapping is deleted.
+func (m *MrMo) deleteFromTargetOrg(ctx context.Context, fm *FileManager, target orgManager.OrgData) (diags diag.Diagnostics) {
+	targetEntityId, err := mockDynamo.GetTargetIdBySourceId(m.Id, target.OrgId)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("No mapping found for %s '%s' in org '%s'", m.ResourceType, m.Id, target.OrgId),
+			Detail:   fmt.Sprintf("Nothing will be destroyed. The config file for the source entity will still be removed. Error: %s", err.Error()),
+		})
+	} else {
+		destroyDiags := destroyWithExecutor(ctx, m.Executor, fm.targetConfigDir, m.ResourceType, targetEntityId)
+		diags = append(diags, destroyDiags...)
+		if executor.IsTimeout(destroyDiags) {
+			diags = append(diags, timeoutDiagnostic(m.ResourceType+" "+targetEntityId, target))
+		}
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	diags = append(diags, fm.deleteArtifacts(ctx)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	diags = append(diags, fm.updateTargetTfConfig(ctx, nil, true)...)
+	if diags.HasError() || targetEntityId == "" {
+		return diags
+	}
+
+	if err = mockDynamo.DeleteTargetInfo(m.Id, target.OrgId); err != nil {
+		diags = append(diags, diag.FromErr(err)...)
+	}
+	return diags
 }
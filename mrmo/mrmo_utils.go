@@ -2,9 +2,10 @@ package mrmo
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	configStore "github.com/charliecon/mr-mo-trial-run/mrmo/config_store"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
 	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -12,11 +13,12 @@ import (
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/mrmo"
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider"
 	providerRegistrar "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider_registrar"
+	resourceExporter "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/tfexporter"
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
-	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util/lists"
 	"log"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -33,8 +35,13 @@ func newMrMo(resourceType string, credentialsFile string, sourceEntityId string)
 	m.OrgManager = credData
 	m.Id = sourceEntityId
 
+	m.ConfigStore, err = configStore.New(credData.Storage)
+	if err != nil {
+		return nil, err
+	}
+
 	// initialise ProviderMeta
-	providerMeta, err := getProviderConfig(*credData)
+	providerMeta, err := getProviderConfig(credData.Source)
 	if err != nil {
 		return nil, err
 	}
@@ -57,12 +64,32 @@ func newMrMo(resourceType string, credentialsFile string, sourceEntityId string)
 	return &m, nil
 }
 
+// getExecutor returns the executor of the instance, creating the one configured in the credentials file on first use.
+// Commands that never run the IaC tool, such as bootstrap and status, don't need its binary to be installed.
+func (m *MrMo) getExecutor() (executor.Executor, error) {
+	if m.Executor != nil {
+		return m.Executor, nil
+	}
+
+	var err error
+	m.Executor, err = newExecutor(m)
+	return m.Executor, err
+}
+
+// newExecutor creates the executor configured in the credentials file
+func newExecutor(m *MrMo) (executor.Executor, error) {
+	if m.OrgManager.Executor.Type == executor.TypeInProcess {
+		return newInProcessExecutor(m)
+	}
+	return executor.New(m.OrgManager.Executor, m.OrgManager.StateBackend)
+}
+
 func createResourceDataObject(resourceSchema map[string]*schema.Schema, data map[string]any) *schema.ResourceData {
 	var t testing.T
 	return schema.TestResourceDataRaw(&t, resourceSchema, data)
 }
 
-func getProviderConfig(orgData orgManager.OrgManager) (_ *provider.ProviderMeta, err error) {
+func getProviderConfig(orgData orgManager.OrgData) (_ *provider.ProviderMeta, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("getProviderConfig: %w", err)
@@ -70,9 +97,9 @@ func getProviderConfig(orgData orgManager.OrgManager) (_ *provider.ProviderMeta,
 	}()
 
 	config := platformclientv2.GetDefaultConfiguration()
-	config.BasePath = provider.GetRegionBasePath(orgData.Source.Region)
+	config.BasePath = provider.GetRegionBasePath(orgData.Region)
 
-	err = config.AuthorizeClientCredentials(orgData.Source.ClientId, orgData.Source.ClientSecret)
+	err = config.AuthorizeClientCredentials(orgData.ClientId, orgData.ClientSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -82,9 +109,13 @@ func getProviderConfig(orgData orgManager.OrgManager) (_ *provider.ProviderMeta,
 	}, nil
 }
 
+// exportDirectory is where the exporter writes files that accompany the exported config (e.g. architect flow YAML)
+const exportDirectory = "./genesyscloud"
+
 // createExportResourceData generates the export resource config that the genesyscloud tf exporter will use
 func createExportResourceData(s map[string]*schema.Schema, resType string) *schema.ResourceData {
 	config := map[string]any{
+		"directory":                exportDirectory,
 		"include_state_file":       true,
 		"export_format":            "json",
 		"include_filter_resources": []any{resType},
@@ -144,43 +175,257 @@ func (m *MrMo) exportConfig(ctx context.Context, resourceId, resourceType string
 	return config, diags
 }
 
-// resolveResourceConfigDependencies will find GUIDS inside the exported tf config and try to resolve them to GUIDs in the target org.
-// This function will return an edited version of resourceConfig, but will not directly edit the parameter resourceConfig.
-func (m *MrMo) resolveResourceConfigDependencies(resourceConfig util.JsonMap, target orgManager.OrgData) (_ util.JsonMap, err error) {
-	defer func() {
+// exportEntities exports every entity of resourceType in entities with a single pass of the exporter, which exports the
+// entities of the ResourceMap it is given when no ID is passed to ExportForMrMo, and returns the resource config of
+// each entity keyed by ID. Exported resources are matched to entities by label. Entities sharing a label are exported
+// on their own, since the exporter relabels them. The exporter of the instance is left unchanged.
+func (m *MrMo) exportEntities(ctx context.Context, resourceType string, entities resourceExporter.ResourceIDMetaMap) (_ map[string]util.JsonMap, diags diag.Diagnostics) {
+	exportResourceConfig := createExportResourceData(tfexporter.ResourceTfExport().Schema, tfexporter.ResourceType)
+
+	gcResourceExporter, newExporterDiags := tfexporter.NewGenesysCloudResourceExporter(ctx, exportResourceConfig, m.ProviderMeta, tfexporter.IncludeResources)
+	diags = append(diags, newExporterDiags...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	registered := providerRegistrar.GetResourceExporterByResourceType(resourceType)
+	if registered == nil {
+		return nil, append(diags, diag.Errorf("no exporter found for resource type '%s'", resourceType)...)
+	}
+
+	labelCounts := make(map[string]int)
+	for _, meta := range entities {
+		if meta != nil {
+			labelCounts[meta.BlockLabel]++
+		}
+	}
+
+	exporter := *registered
+	exporter.ResourceMap = entities
+	config, exportDiags := gcResourceExporter.ExportForMrMo(resourceType, &exporter, "")
+	diags = append(diags, exportDiags...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	resourceBlock, _ := config["resource"].(map[string]tfexporter.ResourceJSONMaps)
+
+	configs := make(map[string]util.JsonMap, len(entities))
+	var relabelled []string
+	for id, meta := range entities {
+		if meta == nil {
+			continue
+		}
+		if labelCounts[meta.BlockLabel] > 1 {
+			relabelled = append(relabelled, id)
+			continue
+		}
+		if resourceConfig, ok := resourceBlock[resourceType][meta.BlockLabel]; ok {
+			configs[id] = resourceConfig
+		}
+	}
+
+	sort.Strings(relabelled)
+	for _, id := range relabelled {
+		config, exportDiags = gcResourceExporter.ExportForMrMo(resourceType, registered, id)
+		diags = append(diags, exportDiags...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		label, err := parseResourceLabelFromConfig(config, resourceType)
 		if err != nil {
-			err = fmt.Errorf("resolveResourceConfigDependencies: %w", err)
+			return nil, append(diags, diag.FromErr(err)...)
 		}
-	}()
+		resourceBlock, _ = config["resource"].(map[string]tfexporter.ResourceJSONMaps)
+		configs[id] = resourceBlock[resourceType][label]
+	}
+	return configs, diags
+}
 
+// resolveResourceConfigDependencies will find GUIDS inside the exported tf config and try to resolve them to GUIDs in the target org.
+// This function will return an edited version of resourceConfig, but will not directly edit the parameter resourceConfig.
+//
+// References are collected from the exporter's RefAttrs (ignoring alternative values such as "*") and from inside its
+// JSON encoded attributes. The target org's manual overrides are consulted first, taking precedence over the mapping
+// table. When a reference has no mapping for the target org, the exporter's custom data source resolver for the
+// attribute is tried, followed by name matching if it is enabled; discovered mappings are recorded, and each mapping
+// found by name is reported as a warning. A reference by name is kept as it is; with name matching enabled, the target
+// org must have an entity with the name. A reference that still cannot be resolved is reported as an error diagnostic
+// in strict mode, and as a warning otherwise, or always as a warning if its resource type is unknown. Unresolved
+// references are never replaced.
+func (m *MrMo) resolveResourceConfigDependencies(ctx context.Context, resourceConfig util.JsonMap, target orgManager.OrgData) (_ util.JsonMap, diags diag.Diagnostics) {
 	copiedConfig := make(util.JsonMap)
 	for k, v := range resourceConfig {
 		copiedConfig[k] = v
 	}
 
-	guidReferencesInConfig := m.extractGuidsUsingExporterRefAttrs(copiedConfig)
+	references := m.extractReferencesUsingExporterRefAttrs(copiedConfig)
+
+	individualResourceConfig := m.getIndividualResourceConfig(copiedConfig)
+	references = append(references, m.extractJsonEncodedReferences(individualResourceConfig)...)
+
+	replacements := make(guidReplacements)
+
+	for _, ref := range references {
+		// names are the same in every org, so the value is kept. With name matching enabled, the target org is checked
+		// to have the entity.
+		if ref.Name != "" {
+			if !m.NameMatching {
+				continue
+			}
+			if err := m.resolveReferenceToName(ctx, ref, target); err != nil {
+				diags = append(diags, m.unresolvedReferenceDiagnostic(ref, target, err))
+			}
+			continue
+		}
+
+		// manual overrides for the target org take precedence over the mapping table
+		if targetGuid, ok := m.resolveReferenceWithOverrides(ctx, ref, target); ok {
+			replacements.add(ref.Location, ref.Guid, targetGuid)
+			continue
+		}
 
-	for _, guid := range guidReferencesInConfig {
 		// search for guid.target.Id value
-		targetGuid, err := mockDynamo.GetTargetIdBySourceId(guid, target.OrgId)
+		targetGuid, err := mockDynamo.GetTargetIdBySourceId(ref.Guid, target.OrgId)
+		if err != nil && m.getDataSourceResolver(ref.Path) != nil {
+			targetGuid, err = m.resolveReferenceWithDataSource(ctx, ref, individualResourceConfig, target)
+		}
+		if err != nil && m.NameMatching && ref.RefType != "" {
+			targetGuid, err = m.resolveReferenceByName(ctx, ref, target)
+			if err == nil {
+				diags = append(diags, nameMatchDiagnostic(ref, targetGuid, target))
+			}
+		}
 		if err != nil {
-			log.Printf("Failed to read guid '%s' from dynamo. Error: %s", guid, err.Error())
+			diags = append(diags, m.unresolvedReferenceDiagnostic(ref, target, err))
 			continue
 		}
 
-		// replace guid with that value
-		copiedConfig, err = replaceGUID(copiedConfig, guid, targetGuid)
-		if err != nil {
-			return nil, err
+		replacements.add(ref.Location, ref.Guid, targetGuid)
+	}
+
+	// replace each guid with its target value, only at the location it was referenced from
+	copiedConfig, err := m.replaceGuidsInResourceConfig(copiedConfig, replacements)
+	if err != nil {
+		return nil, append(diags, diag.FromErr(fmt.Errorf("resolveResourceConfigDependencies: %w", err))...)
+	}
+
+	return copiedConfig, diags
+}
+
+// resolveReferenceWithOverrides looks the reference up in the target org's overrides, first by source ID and then by the
+// name of the referenced entity in the source org
+func (m *MrMo) resolveReferenceWithOverrides(ctx context.Context, ref reference, target orgManager.OrgData) (string, bool) {
+	if len(target.Overrides) == 0 {
+		return "", false
+	}
+
+	if targetGuid, ok := target.Overrides.LookupById(ref.RefType, ref.Guid); ok {
+		log.Printf("Resolved '%s' at '%s' to '%s' using overrides for org '%s'", ref.Guid, ref.Location, targetGuid, target.OrgId)
+		return targetGuid, true
+	}
+
+	if ref.RefType == "" || !target.Overrides.HasNameOverrides(ref.RefType) {
+		return "", false
+	}
+
+	sourceEntities, err := m.getEntities(ctx, ref.RefType, m.OrgManager.Source)
+	if err != nil {
+		log.Printf("Failed to read %s entities to check name overrides. Error: %s", ref.RefType, err.Error())
+		return "", false
+	}
+	sourceEntity, ok := sourceEntities[ref.Guid]
+	if !ok || sourceEntity == nil {
+		return "", false
+	}
+
+	if targetGuid, ok := target.Overrides.LookupByName(ref.RefType, sourceEntity.BlockLabel); ok {
+		log.Printf("Resolved '%s' ('%s') at '%s' to '%s' using overrides for org '%s'", ref.Guid, sourceEntity.BlockLabel, ref.Location, targetGuid, target.OrgId)
+		return targetGuid, true
+	}
+	return "", false
+}
+
+// unresolvedReferenceDiagnostic builds the diagnostic reported when a reference has no mapping for the target org. The
+// severity is an error in strict mode and a warning otherwise.
+func (m *MrMo) unresolvedReferenceDiagnostic(ref reference, target orgManager.OrgData, err error) diag.Diagnostic {
+	// a GUID of unknown type, e.g. found in a JSON encoded attribute, may not reference an entity at all
+	severity := diag.Warning
+	if m.StrictMode && ref.RefType != "" {
+		severity = diag.Error
+	}
+	return diag.Diagnostic{
+		Severity: severity,
+		Summary:  fmt.Sprintf("Unresolved reference in %s", m.ResourcePath),
+		Detail: fmt.Sprintf("Attribute '%s' references %s '%s', which has no mapping for target org '%s' (%s). Error: %s",
+			ref.Location, ref.RefType, ref.value(), target.Name, target.OrgId, err.Error()),
+	}
+}
+
+// verifyNoSourceGuids is a guard run before a target config is written in strict mode. It returns an error diagnostic
+// for every GUID left in the resource config that belongs to the source org, i.e. a GUID that was referenced via
+// RefAttrs or that is tracked as a source entity in the mapping table.
+func (m *MrMo) verifyNoSourceGuids(resourceConfig util.JsonMap, sourceReferences []reference, target orgManager.OrgData) (diags diag.Diagnostics) {
+	resourceBlock, ok := resourceConfig["resource"].(map[string]tfexporter.ResourceJSONMaps)
+	if !ok {
+		return diag.Errorf("failed to read resource block from config for '%s'", m.ResourcePath)
+	}
+
+	// the mapping table is read once, rather than once for every GUID found
+	sourceGuids, err := mockDynamo.GetSourceEntityIds()
+	if err != nil {
+		return diag.Errorf("failed to read the source entities in the mapping table to check config for '%s': %s", m.ResourcePath, err.Error())
+	}
+	for _, ref := range sourceReferences {
+		if ref.Guid != "" {
+			sourceGuids[ref.Guid] = true
 		}
 	}
 
-	return copiedConfig, err
+	for _, location := range findGuidLocations(map[string]any(resourceBlock[m.ResourceType][m.ResourceLabel]), "") {
+		if !sourceGuids[location.Guid] {
+			continue
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Refusing to write config for %s to target org %s", m.ResourcePath, target.OrgId),
+			Detail: fmt.Sprintf("Attribute '%s' still contains source org GUID '%s'. Disable strict mode to write the config anyway.",
+				location.Path, location.Guid),
+		})
+	}
+	return
+}
+
+// getIndividualResourceConfig returns the config of the MrMo resource from inside the exported config
+func (m *MrMo) getIndividualResourceConfig(exportedConfig util.JsonMap) util.JsonMap {
+	resourceBlock, _ := exportedConfig["resource"].(map[string]tfexporter.ResourceJSONMaps)
+	return resourceBlock[m.ResourceType][m.ResourceLabel]
 }
 
-// extractGuidsUsingExporterRefAttrs will use the ResourceExporter's RefAttr configuration to locate the UUIDs
-// inside the exported config.
-func (m *MrMo) extractGuidsUsingExporterRefAttrs(exportedConfig util.JsonMap) (uuids []string) {
+// reference is a GUID found in the exported config at a RefAttrs path
+type reference struct {
+	// the RefAttrs path, e.g. "member_groups.member_group_id"
+	Path string
+	// the exact location of the GUID, e.g. "member_groups[0].member_group_id"
+	Location string
+	RefType  string
+	Guid     string
+	// Name is set instead of Guid for an entity referenced by name, which is the same in every org
+	Name string
+}
+
+// value returns the GUID or the name the reference holds
+func (r reference) value() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Guid
+}
+
+// extractReferencesUsingExporterRefAttrs will use the ResourceExporter's RefAttr configuration to locate the UUIDs
+// inside the exported config, along with the path they were found at and the type of resource they reference. A value
+// that is not a GUID is returned as a reference by name when its RefType is known, unless it is an interpolation such
+// as "${genesyscloud_flow.example.id}".
+func (m *MrMo) extractReferencesUsingExporterRefAttrs(exportedConfig util.JsonMap) (references []reference) {
 	if m.Exporter == nil || m.Exporter.RefAttrs == nil || len(m.Exporter.RefAttrs) == 0 {
 		return
 	}
@@ -191,89 +436,252 @@ func (m *MrMo) extractGuidsUsingExporterRefAttrs(exportedConfig util.JsonMap) (u
 	allResourceByResourceType := resourceBlock[m.ResourceType]
 	individualResourceConfig := allResourceByResourceType[m.ResourceLabel]
 
-	for path := range m.Exporter.RefAttrs {
-		valueFoundInExportedConfig := getValueAtPath(individualResourceConfig, path)
-		if valueFoundInExportedConfig == nil {
+	for path, settings := range m.Exporter.RefAttrs {
+		valuesFoundInExportedConfig := findValuesAtPath(individualResourceConfig, path)
+		if len(valuesFoundInExportedConfig) == 0 {
 			log.Printf("No value found at path '%s'", path)
 			continue
 		}
 
-		uuids = append(uuids, convertValueAtPathToStringSlice(valueFoundInExportedConfig)...)
+		var refType string
+		if settings != nil {
+			refType = settings.RefType
+		}
+		for _, value := range valuesFoundInExportedConfig {
+			guid, ok := value.Value.(string)
+			if !ok || guid == "" || isAltValue(settings, guid) {
+				continue
+			}
+			if !isGuid(guid) {
+				if refType == "" || strings.Contains(guid, "${") {
+					log.Printf("Value '%s' at '%s' is not a GUID. Skipping", guid, value.Location)
+					continue
+				}
+				references = append(references, reference{
+					Path:     path,
+					Location: value.Location,
+					RefType:  refType,
+					Name:     guid,
+				})
+				continue
+			}
+			references = append(references, reference{
+				Path:     path,
+				Location: value.Location,
+				RefType:  refType,
+				Guid:     guid,
+			})
+		}
 	}
 
-	log.Printf("Collected %d GUIDs for resource %s", len(uuids), m.ResourcePath)
+	log.Printf("Collected %d GUIDs for resource %s", len(references), m.ResourcePath)
 	return
 }
 
-func convertValueAtPathToStringSlice(v any) []string {
-	if vInterfaceSlice, ok := v.([]interface{}); ok {
-		return lists.InterfaceListToStrings(vInterfaceSlice)
-	} else if vStringSlice, ok := v.([]string); ok {
-		return vStringSlice
+// getValuesAtPath returns every value found at path, descending through nested maps and through each element of any
+// list encountered along the way. This allows paths such as "resources.filename" to match inside lists of blocks.
+func getValuesAtPath(config map[string]any, path string) (values []any) {
+	for _, location := range findValuesAtPath(config, path) {
+		values = append(values, location.Value)
 	}
-	return []string{v.(string)}
+	return
 }
 
-func getValueAtPath(config util.JsonMap, path string) (v any) {
-	configCopy := replaceMap(config)
+// valueLocation is a value found in a resource config, along with its exact location, e.g. "member_groups[1].member_group_id"
+type valueLocation struct {
+	Location string
+	Value    any
+}
+
+// findValuesAtPath returns every value found at the RefAttrs style path, along with its exact location. Terraform JSON
+// represents nested blocks as lists of objects, so each element of any list found along the path is searched. A list
+// found at the end of the path is expanded so that each of its elements is returned with its own location.
+func findValuesAtPath(config map[string]any, path string) []valueLocation {
+	return collectValuesAtKeys(config, strings.Split(path, "."), "")
+}
 
-	allKeys := strings.Split(path, ".")
-	for _, key := range allKeys {
-		valueAtKey := configCopy[key]
-		if valueAtKey == nil {
-			log.Printf("no value found at path '%s'", path)
-			return
+func collectValuesAtKeys(v any, keys []string, location string) (values []valueLocation) {
+	childLocation := func(key string) string {
+		if location == "" {
+			return key
 		}
-		if mapValue, ok := valueAtKey.(map[string]any); ok {
-			configCopy = replaceMap(mapValue)
-			continue
+		return location + "." + key
+	}
+
+	switch typed := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		for i, element := range typed {
+			values = append(values, collectValuesAtKeys(element, keys, fmt.Sprintf("%s[%d]", location, i))...)
 		}
-		v = valueAtKey
-		break
+		return
+	case []string:
+		for i, element := range typed {
+			values = append(values, collectValuesAtKeys(element, keys, fmt.Sprintf("%s[%d]", location, i))...)
+		}
+		return
+	}
+
+	if len(keys) == 0 {
+		return []valueLocation{{Location: location, Value: v}}
 	}
 
+	switch typed := v.(type) {
+	case map[string]any:
+		return collectValuesAtKeys(typed[keys[0]], keys[1:], childLocation(keys[0]))
+	case util.JsonMap:
+		return collectValuesAtKeys(typed[keys[0]], keys[1:], childLocation(keys[0]))
+	}
 	return
 }
 
-// replaceMap will take the parameter m, make and return of clone of this param without directly affecting m
-func replaceMap(m map[string]any) map[string]any {
-	mm := make(map[string]any)
-	for k, v := range m {
-		mm[k] = v
-	}
-	return mm
+// rewriteValuesAtPath returns a copy of config in which every string value found at the RefAttrs style path is replaced
+// by the result of rewrite. Lists along the path are searched like in findValuesAtPath. config itself is not modified.
+func rewriteValuesAtPath(config map[string]any, path string, rewrite func(string) string) map[string]any {
+	rewritten, _ := rewriteValuesAtKeys(config, strings.Split(path, "."), rewrite).(map[string]any)
+	return rewritten
 }
 
-// replaceGUID takes a JsonMap, finds all instances of oldGUID within the data structure
-// and replaces them with newGUID. The function converts the map to a JSON string to perform
-// the replacement, then converts it back to a JsonMap. It returns the modified JsonMap and
-// any error encountered during the process.
-func replaceGUID(data util.JsonMap, oldGUID string, newGUID string) (_ util.JsonMap, err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("replaceGUID: %w", err)
+func rewriteValuesAtKeys(v any, keys []string, rewrite func(string) string) any {
+	switch typed := v.(type) {
+	case string:
+		if len(keys) == 0 {
+			return rewrite(typed)
 		}
-	}()
+	case []string:
+		copied := make([]string, len(typed))
+		for i, element := range typed {
+			copied[i] = rewriteValuesAtKeys(element, keys, rewrite).(string)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(typed))
+		for i, element := range typed {
+			copied[i] = rewriteValuesAtKeys(element, keys, rewrite)
+		}
+		return copied
+	case map[string]any:
+		if len(keys) == 0 {
+			return typed
+		}
+		copied := make(map[string]any, len(typed))
+		for k, element := range typed {
+			copied[k] = element
+		}
+		if element, ok := typed[keys[0]]; ok {
+			copied[keys[0]] = rewriteValuesAtKeys(element, keys[1:], rewrite)
+		}
+		return copied
+	case util.JsonMap:
+		if len(keys) == 0 {
+			return typed
+		}
+		copied := make(util.JsonMap, len(typed))
+		for k, element := range typed {
+			copied[k] = element
+		}
+		if element, ok := typed[keys[0]]; ok {
+			copied[keys[0]] = rewriteValuesAtKeys(element, keys[1:], rewrite)
+		}
+		return copied
+	}
+	return v
+}
 
-	// Convert the data structure to JSON string
-	jsonBytes, err := json.Marshal(data)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling data: %w", err)
+// guidReplacements maps attribute paths to the replacements (source GUID -> target GUID) to make at that path. A path
+// is either a RefAttrs style path, e.g. "member_groups.member_group_id", which matches every element of any list along
+// the way, or an exact location, e.g. "member_groups[0].member_group_id".
+type guidReplacements map[string]map[string]string
+
+func (r guidReplacements) add(path, oldGuid, newGuid string) {
+	if r[path] == nil {
+		r[path] = make(map[string]string)
+	}
+	r[path][oldGuid] = newGuid
+}
+
+// replaceGuidsInResourceConfig returns a copy of resourceConfig in which the GUIDs in the MrMo resource's block are
+// replaced according to replacements. Only string values at the given paths are rewritten, so GUIDs that happen to
+// appear in other attributes (names, descriptions, etc.) are left alone. The typed structure of the config is
+// preserved and resourceConfig itself is not modified.
+func (m *MrMo) replaceGuidsInResourceConfig(resourceConfig util.JsonMap, replacements guidReplacements) (_ util.JsonMap, err error) {
+	if len(replacements) == 0 {
+		return resourceConfig, nil
+	}
+
+	resourceBlock, ok := resourceConfig["resource"].(map[string]tfexporter.ResourceJSONMaps)
+	if !ok {
+		return nil, fmt.Errorf("replaceGuidsInResourceConfig: expected resource block of type map[string]tfexporter.ResourceJSONMaps, got %T", resourceConfig["resource"])
 	}
 
-	// Convert to string
-	jsonStr := string(jsonBytes)
+	resourcesOfType := make(tfexporter.ResourceJSONMaps)
+	for label, config := range resourceBlock[m.ResourceType] {
+		resourcesOfType[label] = config
+	}
+	resourcesOfType[m.ResourceLabel] = replaceGuidsAtPaths(resourcesOfType[m.ResourceLabel], "", "", replacements).(util.JsonMap)
 
-	// Replace the GUID
-	updatedStr := strings.Replace(jsonStr, oldGUID, newGUID, -1)
+	copiedResourceBlock := make(map[string]tfexporter.ResourceJSONMaps)
+	for resourceType, resources := range resourceBlock {
+		copiedResourceBlock[resourceType] = resources
+	}
+	copiedResourceBlock[m.ResourceType] = resourcesOfType
 
-	// Convert back to map
-	var result util.JsonMap
-	if err := json.Unmarshal([]byte(updatedStr), &result); err != nil {
-		return nil, fmt.Errorf("error unmarshaling data: %w", err)
+	copiedConfig := make(util.JsonMap)
+	for k, v := range resourceConfig {
+		copiedConfig[k] = v
+	}
+	copiedConfig["resource"] = copiedResourceBlock
+
+	return copiedConfig, nil
+}
+
+// replaceGuidsAtPaths walks v and returns a copy with the replacements applied to string values. refAttrPath is the path
+// of v without list indices and exactPath is the path of v with list indices.
+func replaceGuidsAtPaths(v any, refAttrPath, exactPath string, replacements guidReplacements) any {
+	childPath := func(path, key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
 	}
 
-	return result, err
+	switch typed := v.(type) {
+	case string:
+		// replacements at the exact location take precedence over those at the RefAttrs path
+		atPath := make(map[string]string)
+		for _, path := range []string{refAttrPath, exactPath} {
+			for oldGuid, newGuid := range replacements[path] {
+				atPath[oldGuid] = newGuid
+			}
+		}
+		return replaceWholeGuids(typed, atPath)
+	case []string:
+		copied := make([]string, len(typed))
+		for i, element := range typed {
+			copied[i] = replaceGuidsAtPaths(element, refAttrPath, fmt.Sprintf("%s[%d]", exactPath, i), replacements).(string)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(typed))
+		for i, element := range typed {
+			copied[i] = replaceGuidsAtPaths(element, refAttrPath, fmt.Sprintf("%s[%d]", exactPath, i), replacements)
+		}
+		return copied
+	case util.JsonMap:
+		copied := make(util.JsonMap)
+		for k, element := range typed {
+			copied[k] = replaceGuidsAtPaths(element, childPath(refAttrPath, k), childPath(exactPath, k), replacements)
+		}
+		return copied
+	case map[string]any:
+		copied := make(map[string]any)
+		for k, element := range typed {
+			copied[k] = replaceGuidsAtPaths(element, childPath(refAttrPath, k), childPath(exactPath, k), replacements)
+		}
+		return copied
+	}
+	return v
 }
 
 // appendOutputBlockToConfig will append an output var to the resource config before applying the resource to the target org.
@@ -287,6 +695,73 @@ func appendOutputBlockToConfig(config util.JsonMap, resourcePath, sourceEntityId
 	return config
 }
 
+const guidPattern = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+
+// guidRegex finds GUIDs inside text, e.g. JSON encoded attributes or flow YAML
+var guidRegex = regexp.MustCompile(guidPattern)
+
+// guidValueRegex only matches a value that is a GUID in its entirety
+var guidValueRegex = regexp.MustCompile("^" + guidPattern + "$")
+
+// findWholeGuids returns every whole GUID in content, in order. A GUID-shaped run of characters that is part of a
+// longer token, e.g. "prefix-<GUID>", is not a whole GUID (see isGuidBoundary).
+func findWholeGuids(content string) (guids []string) {
+	for _, match := range findWholeGuidIndexes([]byte(content)) {
+		guids = append(guids, content[match[0]:match[1]])
+	}
+	return
+}
+
+// findWholeGuidIndexes returns the start and end index of every whole GUID in content. See findWholeGuids.
+func findWholeGuidIndexes(content []byte) (matches [][]int) {
+	for _, match := range guidRegex.FindAllIndex(content, -1) {
+		if isGuidBoundary(content, match[0]-1) && isGuidBoundary(content, match[1]) {
+			matches = append(matches, match)
+		}
+	}
+	return
+}
+
+// replaceWholeGuids returns s with every whole GUID that has a replacement replaced, at the positions it was found, so
+// that replacing one GUID can never change another
+func replaceWholeGuids(s string, replacements map[string]string) string {
+	if len(replacements) == 0 {
+		return s
+	}
+
+	var replaced strings.Builder
+	last := 0
+	for _, match := range findWholeGuidIndexes([]byte(s)) {
+		newGuid, ok := replacements[s[match[0]:match[1]]]
+		if !ok {
+			continue
+		}
+		replaced.WriteString(s[last:match[0]])
+		replaced.WriteString(newGuid)
+		last = match[1]
+	}
+	replaced.WriteString(s[last:])
+	return replaced.String()
+}
+
+// isGuid returns true if the whole of value is a GUID
+func isGuid(value string) bool {
+	return guidValueRegex.MatchString(value)
+}
+
+// uniqueStrings returns the values of s in their original order with any duplicates removed
+func uniqueStrings(s []string) (unique []string) {
+	seen := make(map[string]bool)
+	for _, v := range s {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		unique = append(unique, v)
+	}
+	return
+}
+
 const outputPrefix = "mrmo_"
 
 // buildOutputKey will build the key of the output tf block. They must not start with a number, so the GUID alone will not do.
@@ -1,93 +1,229 @@
 package mrmo
 
 import (
+	"context"
+	"errors"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
 	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
 	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/tfexporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"strings"
 	"testing"
 )
 
-func TestUnitExtractGuidsUsingExporterRefAttrs(t *testing.T) {
-	entityGuid := uuid.NewString()
-	const (
-		resourceType  = "resource_foo_bar"
-		resourceLabel = "example"
-	)
-	fullPath := resourceType + "." + resourceLabel
+func TestUnitFindValuesAtPath(t *testing.T) {
+	config := map[string]any{
+		"queue_flow_ids": []any{"flow-1", "flow-2"},
+		"member_groups": []any{
+			map[string]any{"member_group_id": "group-1"},
+			map[string]any{"member_group_type": "TEAM"},
+			map[string]any{"member_group_id": "group-2"},
+		},
+	}
+
+	expected := map[string][]valueLocation{
+		"queue_flow_ids": {
+			{Location: "queue_flow_ids[0]", Value: "flow-1"},
+			{Location: "queue_flow_ids[1]", Value: "flow-2"},
+		},
+		"member_groups.member_group_id": {
+			{Location: "member_groups[0].member_group_id", Value: "group-1"},
+			{Location: "member_groups[2].member_group_id", Value: "group-2"},
+		},
+		"not_in_config": nil,
+	}
+
+	for path, expectedLocations := range expected {
+		locations := findValuesAtPath(config, path)
+		if len(locations) != len(expectedLocations) {
+			t.Errorf("Expected %d values at path '%s', got %d: %v", len(expectedLocations), path, len(locations), locations)
+			continue
+		}
+		for i := range locations {
+			if locations[i] != expectedLocations[i] {
+				t.Errorf("Expected %v at path '%s', got %v", expectedLocations[i], path, locations[i])
+			}
+		}
+	}
+}
+
+func TestUnitFindUnlistedGuids(t *testing.T) {
+	listedGuid := uuid.NewString()
+	unlistedGuid := uuid.NewString()
+	nestedUnlistedGuid := uuid.NewString()
 
 	m := MrMo{
-		ResourceType: resourceType,
-		Id:           entityGuid,
+		Id: uuid.NewString(),
 		Exporter: &resource_exporter.ResourceExporter{
 			RefAttrs: map[string]*resource_exporter.RefAttrSettings{
-				"base_value_1": {
-					RefType: "example_resource",
-				},
-				"base_value_2": {
-					RefType: "example_resource_two",
-				},
-				"grandparent.parent.grandchild": {
-					RefType: "example_resource_three",
-				},
-				"grandparent.parent.grandchild_2.great_grandchild": {
-					RefType: "example_resource_four",
-				},
-				"not_in_config": {
-					RefType: "example_resource_five",
-				},
+				"queue_id": {RefType: "genesyscloud_routing_queue"},
 			},
 		},
-		ResourcePath:  fullPath,
-		ResourceLabel: resourceLabel,
 	}
 
-	nestedGuid1 := uuid.NewString()
-	nestedGuid2 := uuid.NewString()
-	nestedGuid3 := uuid.NewString()
-	nestedGuid4 := uuid.NewString()
-	nestedGuid5 := uuid.NewString()
-	guidThatShouldNotBeThere := uuid.NewString()
+	config := map[string]any{
+		"queue_id":    listedGuid,
+		"description": "Copied from " + unlistedGuid,
+		"member_groups": []any{
+			map[string]any{"member_group_id": nestedUnlistedGuid},
+		},
+	}
+
+	locations := m.findUnlistedGuids(config)
+	if len(locations) != 2 {
+		t.Fatalf("Expected 2 unlisted GUIDs, got %d: %v", len(locations), locations)
+	}
+
+	expected := map[string]string{
+		"description":                      unlistedGuid,
+		"member_groups[0].member_group_id": nestedUnlistedGuid,
+	}
+	for _, location := range locations {
+		if expected[location.Path] != location.Guid {
+			t.Errorf("Unexpected GUID '%s' at path '%s'", location.Guid, location.Path)
+		}
+	}
+}
+
+func TestUnitReplaceGuidsInResourceConfig(t *testing.T) {
+	const (
+		resourceType  = "resource_foo_bar"
+		resourceLabel = "example"
+	)
+	sourceGuid := uuid.NewString()
+	targetGuid := uuid.NewString()
+
+	m := MrMo{
+		ResourceType:  resourceType,
+		ResourceLabel: resourceLabel,
+	}
 
 	config := map[string]any{
 		"resource": map[string]tfexporter.ResourceJSONMaps{
 			resourceType: {
 				resourceLabel: map[string]any{
-					"base_value_1":         nestedGuid1,
-					"base_value_2":         []any{nestedGuid2},
-					"guid_not_in_refattrs": guidThatShouldNotBeThere,
-					"grandparent": map[string]any{
-						"parent": map[string]any{
-							"grandchild": nestedGuid3,
-							"grandchild_2": map[string]any{
-								"great_grandchild": []string{nestedGuid4, nestedGuid5},
-							},
-						},
+					"queue_id":    sourceGuid,
+					"description": "Copied from " + sourceGuid,
+					"parent": map[string]any{
+						"skill_ids": []any{sourceGuid},
 					},
 				},
 			},
 		},
 	}
 
-	guids := m.extractGuidsUsingExporterRefAttrs(config)
-	validateStringsExistInSlice(t, guids, nestedGuid1, nestedGuid2, nestedGuid3, nestedGuid4, nestedGuid5)
-	if stringInStringSlice(guids, guidThatShouldNotBeThere) {
-		t.Errorf("Expected %s to not be in slice", guidThatShouldNotBeThere)
+	replacements := make(guidReplacements)
+	replacements.add("queue_id", sourceGuid, targetGuid)
+	replacements.add("parent.skill_ids", sourceGuid, targetGuid)
+
+	result, err := m.replaceGuidsInResourceConfig(config, replacements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resourceBlock, ok := result["resource"].(map[string]tfexporter.ResourceJSONMaps)
+	if !ok {
+		t.Fatalf("Expected resource block to keep type map[string]tfexporter.ResourceJSONMaps, got %T", result["resource"])
+	}
+	resourceConfig := resourceBlock[resourceType][resourceLabel]
+
+	if v := getValuesAtPath(resourceConfig, "queue_id"); len(v) != 1 || v[0] != targetGuid {
+		t.Errorf("Expected queue_id to be '%s', got '%v'", targetGuid, v)
+	}
+	if v := getValuesAtPath(resourceConfig, "parent.skill_ids"); len(v) == 0 || v[0] != targetGuid {
+		t.Errorf("Expected parent.skill_ids to contain '%s', got '%v'", targetGuid, v)
+	}
+	if v := getValuesAtPath(resourceConfig, "description"); len(v) != 1 || v[0] != "Copied from "+sourceGuid {
+		t.Errorf("Expected description to be left alone, got '%v'", v)
+	}
+
+	originalConfig := config["resource"].(map[string]tfexporter.ResourceJSONMaps)[resourceType][resourceLabel]
+	if v := getValuesAtPath(originalConfig, "queue_id"); len(v) != 1 || v[0] != sourceGuid {
+		t.Errorf("Expected original config to be unmodified, got queue_id '%v'", v)
 	}
 }
 
-func validateStringsExistInSlice(t *testing.T, slice []string, s ...string) {
-	for _, v := range s {
-		if !stringInStringSlice(slice, v) {
-			t.Errorf("Expected %s to be in returned slice", v)
+func TestUnitOverridesTakePrecedenceOverMappings(t *testing.T) {
+	const refType = "genesyscloud_telephony_providers_edges_site"
+	sourceGuid := uuid.NewString()
+	overrideGuid := uuid.NewString()
+	mockDynamo.UseTestTable(t, mappingItem(refType, sourceGuid, "org-a", "mapped-site"))
+
+	m := newTestMrMo(t)
+	m.Exporter = &resource_exporter.ResourceExporter{
+		RefAttrs: map[string]*resource_exporter.RefAttrSettings{"site_id": {RefType: refType}},
+	}
+	config := resolverTestConfig(util.JsonMap{"site_id": sourceGuid})
+
+	target := orgManager.OrgData{OrgId: "org-a"}
+	resolved, diags := m.resolveResourceConfigDependencies(context.Background(), config, target)
+	if len(diags) != 0 || resolvedTestValue(t, m, resolved, "site_id") != "mapped-site" {
+		t.Fatalf("expected the mapping to be used without overrides, got %v (%v)", resolvedTestValue(t, m, resolved, "site_id"), diags)
+	}
+
+	target.Overrides = orgManager.Overrides{refType: {{SourceId: sourceGuid, TargetId: overrideGuid}}}
+	resolved, diags = m.resolveResourceConfigDependencies(context.Background(), config, target)
+	if len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+	if value := resolvedTestValue(t, m, resolved, "site_id"); value != overrideGuid {
+		t.Errorf("expected the override '%s' to take precedence over the mapping, got '%v'", overrideGuid, value)
+	}
+}
+
+func TestUnitVerifyNoSourceGuids(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a"}
+	referencedGuid := uuid.NewString()
+	trackedGuid := uuid.NewString()
+	unknownGuid := uuid.NewString()
+	mockDynamo.UseTestTable(t, mappingItem("genesyscloud_routing_skill", trackedGuid, target.OrgId, "target-skill"))
+
+	m := newTestMrMo(t)
+	sourceReferences := []reference{{Path: "queue_id", Guid: referencedGuid}, {Path: "flow_name", Name: "Inbound"}}
+
+	config := resolverTestConfig(util.JsonMap{
+		"queue_id":    referencedGuid,
+		"description": "Copied from " + trackedGuid,
+		"external_id": unknownGuid,
+	})
+	diags := m.verifyNoSourceGuids(config, sourceReferences, target)
+	if len(diags) != 2 || !diags.HasError() {
+		t.Fatalf("expected an error for the referenced and the tracked GUID, got %v", diags)
+	}
+	for _, guid := range []string{referencedGuid, trackedGuid} {
+		found := false
+		for _, d := range diags {
+			found = found || strings.Contains(d.Detail, guid)
+		}
+		if !found {
+			t.Errorf("expected an error for '%s', got %v", guid, diags)
 		}
 	}
+
+	config = resolverTestConfig(util.JsonMap{"queue_id": "target-queue", "external_id": unknownGuid})
+	if diags = m.verifyNoSourceGuids(config, sourceReferences, target); len(diags) != 0 {
+		t.Errorf("expected no errors once the source GUIDs are resolved, got %v", diags)
+	}
 }
 
-func stringInStringSlice(slice []string, s string) bool {
-	for _, v := range slice {
-		if v == s {
-			return true
+func TestUnitUnresolvedReferenceDiagnostic(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a", Name: "Org A"}
+	ref := reference{Location: "queue_id", RefType: "genesyscloud_routing_queue", Guid: uuid.NewString()}
+
+	for strict, severity := range map[bool]diag.Severity{false: diag.Warning, true: diag.Error} {
+		m := newTestMrMo(t)
+		m.StrictMode = strict
+		d := m.unresolvedReferenceDiagnostic(ref, target, errors.New("not found"))
+		if d.Severity != severity {
+			t.Errorf("expected severity %v in strict mode %t, got %v", severity, strict, d.Severity)
+		}
+		for _, expected := range []string{ref.Location, ref.Guid, target.OrgId, "not found"} {
+			if !strings.Contains(d.Detail, expected) {
+				t.Errorf("expected the detail to mention '%s', got '%s'", expected, d.Detail)
+			}
 		}
 	}
-	return false
 }
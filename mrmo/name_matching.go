@@ -0,0 +1,304 @@
+package mrmo
+
+import (
+	"context"
+	"fmt"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/mrmo"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider"
+	providerRegistrar "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider_registrar"
+	resourceExporter "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/util"
+	"log"
+	"sort"
+)
+
+// resolveReferenceByName is the fallback used, when name matching is enabled, for a reference that has no mapping for
+// the target org. It reads the name of the referenced entity in the source org, looks for an entity of the RefType
+// with exactly the same name in the target org, and records the discovered mapping in the mapping table.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - ref: The unresolved reference
+//   - target: The target org data
+//
+// Returns:
+//   - string: The ID of the matching entity in the target org
+//   - error: Error if the entity cannot be found in either org, more than one target entity has the same name, or the
+//     matching target entity is already mapped to another source entity
+func (m *MrMo) resolveReferenceByName(ctx context.Context, ref reference, target orgManager.OrgData) (_ string, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to match %s '%s' by name: %w", ref.RefType, ref.Guid, err)
+		}
+	}()
+
+	sourceEntities, err := m.getEntities(ctx, ref.RefType, m.OrgManager.Source)
+	if err != nil {
+		return "", err
+	}
+
+	sourceEntity, ok := sourceEntities[ref.Guid]
+	if !ok || sourceEntity == nil {
+		return "", fmt.Errorf("entity not found in source org")
+	}
+	name, err := m.getEntityName(ctx, ref.RefType, ref.Guid, m.OrgManager.Source)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("entity has no name in source org")
+	}
+
+	targetGuid, err := m.findEntityIdByName(ctx, ref.RefType, target, name, sourceEntity.BlockLabel)
+	if err != nil {
+		return "", err
+	}
+	if err = ensureTargetNotMappedElsewhere(ref.RefType, targetGuid, target.OrgId, ref.Guid); err != nil {
+		return "", err
+	}
+
+	log.Printf("Warning: Matched %s '%s' to '%s' in org '%s' by name '%s'. Recording the mapping", ref.RefType, ref.Guid, targetGuid, target.OrgId, name)
+	if err = mockDynamo.UpdateItem(ref.RefType, ref.Guid, target.OrgId, targetGuid); err != nil {
+		return "", err
+	}
+	return targetGuid, nil
+}
+
+// nameMatchDiagnostic returns the warning reported for a reference that was resolved by name, so that the recorded
+// mapping can be reviewed
+func nameMatchDiagnostic(ref reference, targetGuid string, target orgManager.OrgData) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("Matched %s '%s' to '%s' in org '%s' by name", ref.RefType, ref.Guid, targetGuid, target.OrgId),
+		Detail:   fmt.Sprintf("The mapping of '%s' at '%s' was recorded in the mapping table. Remove it if the entities are not the same.", ref.Guid, ref.Location),
+	}
+}
+
+// findEntityIdByName returns the ID of the only entity of resourceType in org with the given name. See
+// findEntityIdsByName.
+func (m *MrMo) findEntityIdByName(ctx context.Context, resourceType string, org orgManager.OrgData, name, label string) (string, error) {
+	matches, err := m.findEntityIdsByName(ctx, resourceType, org, name, label)
+	if err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no entity named '%s' found in org '%s'", name, org.OrgId)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("found %d entities named '%s' in org '%s': %v", len(matches), name, org.OrgId, matches)
+}
+
+// findEntityIdsByLabel returns the sorted IDs of every entity in entities with the given label
+func findEntityIdsByLabel(entities resourceExporter.ResourceIDMetaMap, label string) []string {
+	var matches []string
+	for id, meta := range entities {
+		if meta != nil && meta.BlockLabel == label {
+			matches = append(matches, id)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// entityNameAttribute is the attribute holding the name of an entity in its exported config
+const entityNameAttribute = "name"
+
+// findEntityIdsByName returns the sorted IDs of every entity of resourceType in org with the given name. Entities are
+// narrowed down by label first, since the exporter derives the label of an entity from its name, and the name of each
+// candidate is then read from its exported config. Labels alone are not enough: different names can sanitize to the
+// same label (e.g. "Foo Bar" and "Foo_Bar"). When the label is not known, every entity of resourceType is a candidate.
+// The names of all candidates are read from a single export of resourceType in org (see getEntityConfigs).
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - resourceType: The resource type of the entities
+//   - org: The org to search
+//   - name: The name to match
+//   - label: The label the exporter gives entities with that name, or an empty string if it is not known
+//
+// Returns:
+//   - []string: The IDs of the matching entities
+//   - error: Error if the entities or their names cannot be read
+func (m *MrMo) findEntityIdsByName(ctx context.Context, resourceType string, org orgManager.OrgData, name, label string) ([]string, error) {
+	entities, err := m.getEntities(ctx, resourceType, org)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := findEntityIdsByLabel(entities, label)
+	if label == "" {
+		candidates = make([]string, 0, len(entities))
+		for id := range entities {
+			candidates = append(candidates, id)
+		}
+		sort.Strings(candidates)
+	}
+
+	var matches []string
+	for _, id := range candidates {
+		candidateName, err := m.getEntityName(ctx, resourceType, id, org)
+		if err != nil {
+			return nil, err
+		}
+		if candidateName == name {
+			matches = append(matches, id)
+		}
+	}
+	return matches, nil
+}
+
+// getEntityName returns the name of the entity in org, read from its exported config, or an empty string if it has
+// none
+func (m *MrMo) getEntityName(ctx context.Context, resourceType, id string, org orgManager.OrgData) (string, error) {
+	return m.getEntityAttribute(ctx, resourceType, id, org, entityNameAttribute)
+}
+
+// getEntityAttribute returns the string value at path in the exported config of the entity in org, or an empty string
+// if there is none
+func (m *MrMo) getEntityAttribute(ctx context.Context, resourceType, id string, org orgManager.OrgData, path string) (string, error) {
+	configs, err := m.getEntityConfigs(ctx, resourceType, org)
+	if err != nil {
+		return "", err
+	}
+	for _, value := range getValuesAtPath(configs[id], path) {
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	}
+	return "", nil
+}
+
+// getEntityConfigs returns the exported config of every entity of resourceType in org, keyed by ID. The entities are
+// exported together, in a single pass of the exporter with the client config of org active (see exportEntities).
+// Results are cached on the MrMo instance.
+func (m *MrMo) getEntityConfigs(ctx context.Context, resourceType string, org orgManager.OrgData) (map[string]util.JsonMap, error) {
+	if configs, ok := m.entityConfigCache[org.OrgId][resourceType]; ok {
+		return configs, nil
+	}
+
+	entities, err := m.getEntities(ctx, resourceType, org)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs map[string]util.JsonMap
+	err = m.withOrg(org, func() error {
+		var diags diag.Diagnostics
+		configs, diags = m.exportEntities(ctx, resourceType, entities)
+		if diags.HasError() {
+			return fmt.Errorf("failed to export %s entities from org '%s': %v", resourceType, org.OrgId, diags)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if m.entityConfigCache == nil {
+		m.entityConfigCache = make(map[string]map[string]map[string]util.JsonMap)
+	}
+	if m.entityConfigCache[org.OrgId] == nil {
+		m.entityConfigCache[org.OrgId] = make(map[string]map[string]util.JsonMap)
+	}
+	m.entityConfigCache[org.OrgId][resourceType] = configs
+	return configs, nil
+}
+
+// ensureTargetNotMappedElsewhere returns an error if the target entity is already mapped to a source entity other
+// than sourceEntityId in the target org, since mapping it again would have two source entities manage it
+func ensureTargetNotMappedElsewhere(resourceType, targetEntityId, targetOrgId, sourceEntityId string) error {
+	mappedSourceId, err := mockDynamo.GetSourceIdByTargetId(targetEntityId, targetOrgId)
+	if err != nil {
+		return err
+	}
+	if mappedSourceId != "" && mappedSourceId != sourceEntityId {
+		return fmt.Errorf("%s '%s' in org '%s' is already mapped to source entity '%s'", resourceType, targetEntityId, targetOrgId, mappedSourceId)
+	}
+	return nil
+}
+
+// getEntities returns every entity of resourceType in the given org, keyed by ID. The client config of the org is
+// activated for the duration of the call and the source org client config is restored afterwards. Results are cached
+// on the MrMo instance.
+func (m *MrMo) getEntities(ctx context.Context, resourceType string, org orgManager.OrgData) (resourceExporter.ResourceIDMetaMap, error) {
+	if entities, ok := m.entityCache[org.OrgId][resourceType]; ok {
+		return entities, nil
+	}
+
+	exporter := providerRegistrar.GetResourceExporterByResourceType(resourceType)
+	if exporter == nil || exporter.GetResourcesFunc == nil {
+		return nil, fmt.Errorf("no exporter found for resource type '%s'", resourceType)
+	}
+
+	var entities resourceExporter.ResourceIDMetaMap
+	err := m.withOrg(org, func() error {
+		var diags diag.Diagnostics
+		entities, diags = exporter.GetResourcesFunc(ctx)
+		if diags.HasError() {
+			return fmt.Errorf("failed to read %s entities from org '%s': %v", resourceType, org.OrgId, diags)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if m.entityCache == nil {
+		m.entityCache = make(map[string]map[string]resourceExporter.ResourceIDMetaMap)
+	}
+	if m.entityCache[org.OrgId] == nil {
+		m.entityCache[org.OrgId] = make(map[string]resourceExporter.ResourceIDMetaMap)
+	}
+	m.entityCache[org.OrgId][resourceType] = entities
+	return entities, nil
+}
+
+// withOrg runs f with the client config and ProviderMeta of org active, restoring the source org afterwards. When org is
+// the source org, f is run as-is.
+func (m *MrMo) withOrg(org orgManager.OrgData, f func() error) error {
+	if org.OrgId == m.OrgManager.Source.OrgId {
+		return f()
+	}
+
+	sourceProviderMeta, ok := m.ProviderMeta.(*provider.ProviderMeta)
+	if !ok {
+		return fmt.Errorf("expected provider meta of type *provider.ProviderMeta, got %T", m.ProviderMeta)
+	}
+	orgProviderMeta, err := m.getOrgProviderMeta(org)
+	if err != nil {
+		return err
+	}
+
+	mrmo.Activate(orgProviderMeta.ClientConfig)
+	m.ProviderMeta = orgProviderMeta
+	defer func() {
+		mrmo.Activate(sourceProviderMeta.ClientConfig)
+		m.ProviderMeta = sourceProviderMeta
+	}()
+
+	return f()
+}
+
+// getOrgProviderMeta returns the ProviderMeta of org, authenticating with its client credentials the first time it is
+// needed. Results are cached on the MrMo instance.
+func (m *MrMo) getOrgProviderMeta(org orgManager.OrgData) (*provider.ProviderMeta, error) {
+	if providerMeta, ok := m.orgProviderMetas[org.OrgId]; ok {
+		return providerMeta, nil
+	}
+
+	providerMeta, err := getProviderConfig(org)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.orgProviderMetas == nil {
+		m.orgProviderMetas = make(map[string]*provider.ProviderMeta)
+	}
+	m.orgProviderMetas[org.OrgId] = providerMeta
+	return providerMeta, nil
+}
@@ -0,0 +1,127 @@
+package mrmo
+
+import (
+	"context"
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/provider"
+	resourceExporter "github.com/mypurecloud/terraform-provider-genesyscloud/genesyscloud/resource_exporter"
+	"reflect"
+	"testing"
+)
+
+const nameMatchingTestResourceType = "genesyscloud_routing_skill"
+
+func TestUnitFindEntityIdsByLabel(t *testing.T) {
+	entities := resourceExporter.ResourceIDMetaMap{
+		"id-3": {BlockLabel: "Foo_Bar"},
+		"id-1": {BlockLabel: "Foo_Bar"},
+		"id-2": {BlockLabel: "Other"},
+		"id-4": nil,
+	}
+
+	if matches := findEntityIdsByLabel(entities, "Foo_Bar"); !reflect.DeepEqual(matches, []string{"id-1", "id-3"}) {
+		t.Errorf("expected the sorted IDs [id-1 id-3], got %v", matches)
+	}
+	if matches := findEntityIdsByLabel(entities, "Missing"); len(matches) != 0 {
+		t.Errorf("expected no matches, got %v", matches)
+	}
+}
+
+func TestUnitFindEntityIdByName(t *testing.T) {
+	org := orgManager.OrgData{OrgId: "org-a"}
+	m := &MrMo{}
+	withCachedEntities(m, org.OrgId, nameMatchingTestResourceType, map[string]string{
+		"id-1": "Foo Bar",
+		"id-2": "Foo_Bar",
+		"id-3": "Twin",
+		"id-4": "Twin",
+	})
+
+	tests := []struct {
+		name, label string
+		expected    string
+		expectError bool
+	}{
+		{name: "Foo Bar", label: "Foo_Bar", expected: "id-1"},
+		{name: "Foo_Bar", label: "Foo_Bar", expected: "id-2"},
+		{name: "Foo_Bar", label: "", expected: "id-2"},
+		{name: "Missing", label: "Missing", expectError: true},
+		{name: "Twin", label: "Twin", expectError: true},
+	}
+	for _, test := range tests {
+		id, err := m.findEntityIdByName(context.Background(), nameMatchingTestResourceType, org, test.name, test.label)
+		if test.expectError {
+			if err == nil {
+				t.Errorf("expected an error for name '%s', got '%s'", test.name, id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for name '%s': %v", test.name, err)
+		} else if id != test.expected {
+			t.Errorf("expected name '%s' to match '%s', got '%s'", test.name, test.expected, id)
+		}
+	}
+}
+
+func TestUnitResolveReferenceByName(t *testing.T) {
+	target := orgManager.OrgData{OrgId: "org-a"}
+	ref := reference{RefType: nameMatchingTestResourceType, Guid: "source-1", Location: "skill_id"}
+
+	newNameMatchingMrMo := func() *MrMo {
+		m := &MrMo{OrgManager: &orgManager.OrgManager{Source: orgManager.OrgData{OrgId: "source-org"}}}
+		withCachedEntities(m, "source-org", nameMatchingTestResourceType, map[string]string{"source-1": "Foo Bar"})
+		return withCachedEntities(m, target.OrgId, nameMatchingTestResourceType, map[string]string{"target-1": "Foo_Bar", "target-2": "Foo Bar"})
+	}
+
+	t.Run("records the mapping", func(t *testing.T) {
+		mockDynamo.UseTestTable(t)
+		targetGuid, err := newNameMatchingMrMo().resolveReferenceByName(context.Background(), ref, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if targetGuid != "target-2" {
+			t.Errorf("expected 'target-2', got '%s'", targetGuid)
+		}
+		if mapped, err := mockDynamo.GetTargetIdBySourceId("source-1", target.OrgId); err != nil || mapped != "target-2" {
+			t.Errorf("expected the mapping to target-2 to be recorded, got '%s' (%v)", mapped, err)
+		}
+	})
+
+	t.Run("refuses a target mapped to another source entity", func(t *testing.T) {
+		mockDynamo.UseTestTable(t, mappingItem(nameMatchingTestResourceType, "source-2", target.OrgId, "target-2"))
+		if _, err := newNameMatchingMrMo().resolveReferenceByName(context.Background(), ref, target); err == nil {
+			t.Error("expected an error for a target entity mapped to another source entity")
+		}
+		if mapped, _ := mockDynamo.GetTargetIdBySourceId("source-1", target.OrgId); mapped != "" {
+			t.Errorf("expected no mapping to be recorded, got '%s'", mapped)
+		}
+	})
+}
+
+func TestUnitWithOrgReusesProviderMeta(t *testing.T) {
+	sourceMeta := &provider.ProviderMeta{Organization: "source-org"}
+	targetMeta := &provider.ProviderMeta{Organization: "org-a"}
+	m := &MrMo{
+		ProviderMeta:     sourceMeta,
+		OrgManager:       &orgManager.OrgManager{Source: orgManager.OrgData{OrgId: "source-org"}},
+		orgProviderMetas: map[string]*provider.ProviderMeta{"org-a": targetMeta},
+	}
+
+	// the cached ProviderMeta is used, rather than authenticating with the org's (empty) client credentials again
+	for i := 0; i < 2; i++ {
+		err := m.withOrg(orgManager.OrgData{OrgId: "org-a"}, func() error {
+			if m.ProviderMeta != targetMeta {
+				t.Errorf("expected the ProviderMeta of org-a to be active, got %v", m.ProviderMeta)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.ProviderMeta != sourceMeta {
+			t.Errorf("expected the source ProviderMeta to be restored, got %v", m.ProviderMeta)
+		}
+	}
+}
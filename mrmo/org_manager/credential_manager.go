@@ -2,6 +2,7 @@ package org_manager
 
 import (
 	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/config"
 	"gopkg.in/yaml.v2"
 	"os"
 	"path/filepath"
@@ -10,6 +11,40 @@ import (
 type OrgManager struct {
 	Source  OrgData   `yaml:"source"`
 	Targets []OrgData `yaml:"targets"`
+
+	// Executor configures the IaC tool used to apply target configs
+	Executor config.Executor `yaml:"executor"`
+	// StateBackend configures where the state of each target org is stored
+	StateBackend config.StateBackend `yaml:"stateBackend"`
+	// Storage configures where target configs are stored
+	Storage config.Storage `yaml:"storage"`
+	// Layout sets how the configs of a target org are split into files: one file per source entity ("entity", the
+	// default) or one file per resource type ("resourceType")
+	Layout string `yaml:"layout"`
+	// History records every change to the target config directories as git commits
+	History HistoryConfig `yaml:"history"`
+	// Backups configures the snapshots taken before each message is applied to a target org
+	Backups BackupsConfig `yaml:"backups"`
+	// ProviderVersion overrides the genesyscloud provider version pinned in target configs. Defaults to the version
+	// the exporter was built with.
+	ProviderVersion string `yaml:"providerVersion"`
+}
+
+// HistoryConfig configures the git history of the target config directories
+type HistoryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Dir holds the repository of each target org, named <org ID>.git. Defaults to .mrmo/history.
+	Dir string `yaml:"dir"`
+	// AuthorName and AuthorEmail are the author of the commits. Default to "Mr Mo" and "mrmo@localhost".
+	AuthorName  string `yaml:"authorName"`
+	AuthorEmail string `yaml:"authorEmail"`
+}
+
+// BackupsConfig configures the snapshots of the target orgs
+type BackupsConfig struct {
+	// Retain is the number of snapshots kept for each target org. The oldest snapshots are deleted after each backup.
+	// Defaults to 100.
+	Retain int `yaml:"retain"`
 }
 
 type OrgData struct {
@@ -18,6 +53,12 @@ type OrgData struct {
 	ClientId     string `yaml:"clientId"`
 	ClientSecret string `yaml:"clientSecret"`
 	Region       string `yaml:"region"`
+	// ProviderAlias, if set, adds an aliased genesyscloud provider configuration to the target's config directory
+	ProviderAlias string `yaml:"providerAlias"`
+
+	// OverridesFile is an optional file of manual mappings for references that cannot be paired automatically
+	OverridesFile string    `yaml:"overridesFile"`
+	Overrides     Overrides `yaml:"-"`
 }
 
 func ParseCredentialData(credsFilePath string) (*OrgManager, error) {
@@ -37,6 +78,10 @@ func ParseCredentialData(credsFilePath string) (*OrgManager, error) {
 		return nil, err
 	}
 
+	if err = secretManager.loadOverrides(filename); err != nil {
+		return nil, err
+	}
+
 	return &secretManager, nil
 }
 
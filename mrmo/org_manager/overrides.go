@@ -0,0 +1,128 @@
+package org_manager
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Overrides are manual mappings for references that cannot be paired automatically (sites, edge groups, external
+// contacts, etc.), keyed by resource type
+type Overrides map[string][]Override
+
+// Override maps a source org entity, identified by either its ID or its name, to an entity in the target org
+type Override struct {
+	SourceId   string `yaml:"sourceId"`
+	SourceName string `yaml:"sourceName"`
+	TargetId   string `yaml:"targetId"`
+}
+
+var guidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ParseOverrides reads and validates an overrides file
+func ParseOverrides(overridesFilePath string) (_ Overrides, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to load overrides file '%s': %w", overridesFilePath, err)
+		}
+	}()
+
+	yamlFile, err := os.ReadFile(overridesFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides Overrides
+	if err = yaml.UnmarshalStrict(yamlFile, &overrides); err != nil {
+		return nil, err
+	}
+
+	return overrides, overrides.validate()
+}
+
+func (o Overrides) validate() error {
+	for resourceType, overrides := range o {
+		seen := make(map[string]bool)
+		for i, override := range overrides {
+			if (override.SourceId == "") == (override.SourceName == "") {
+				return fmt.Errorf("%s override %d must set exactly one of sourceId and sourceName", resourceType, i)
+			}
+			if override.SourceId != "" && !guidRegex.MatchString(override.SourceId) {
+				return fmt.Errorf("%s override %d has invalid sourceId '%s'", resourceType, i, override.SourceId)
+			}
+			if !guidRegex.MatchString(override.TargetId) {
+				return fmt.Errorf("%s override %d has invalid targetId '%s'", resourceType, i, override.TargetId)
+			}
+
+			key := "id:" + override.SourceId
+			if override.SourceName != "" {
+				key = "name:" + override.SourceName
+			}
+			if seen[key] {
+				return fmt.Errorf("%s has more than one override for %s", resourceType, key)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// LookupById returns the target ID overriding the source entity ID. If resourceType is empty, the overrides of every
+// resource type are searched.
+func (o Overrides) LookupById(resourceType, sourceId string) (string, bool) {
+	for overrideResourceType, overrides := range o {
+		if resourceType != "" && overrideResourceType != resourceType {
+			continue
+		}
+		for _, override := range overrides {
+			if override.SourceId != "" && override.SourceId == sourceId {
+				return override.TargetId, true
+			}
+		}
+	}
+	return "", false
+}
+
+// LookupByName returns the target ID overriding the source entity with the given name
+func (o Overrides) LookupByName(resourceType, sourceName string) (string, bool) {
+	for _, override := range o[resourceType] {
+		if override.SourceName != "" && override.SourceName == sourceName {
+			return override.TargetId, true
+		}
+	}
+	return "", false
+}
+
+// HasNameOverrides returns true if any override for resourceType identifies the source entity by name
+func (o Overrides) HasNameOverrides(resourceType string) bool {
+	for _, override := range o[resourceType] {
+		if override.SourceName != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadOverrides loads the overrides file of every target org that defines one. Relative paths are resolved against
+// the directory of the credentials file.
+func (o *OrgManager) loadOverrides(credsFilePath string) error {
+	for i := range o.Targets {
+		target := &o.Targets[i]
+		if target.OverridesFile == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(target.OverridesFile) {
+			target.OverridesFile = filepath.Join(filepath.Dir(credsFilePath), target.OverridesFile)
+		}
+
+		overrides, err := ParseOverrides(target.OverridesFile)
+		if err != nil {
+			return fmt.Errorf("target org '%s': %w", target.Name, err)
+		}
+		target.Overrides = overrides
+	}
+	return nil
+}
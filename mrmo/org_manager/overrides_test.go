@@ -0,0 +1,59 @@
+package org_manager
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestUnitOverridesValidate(t *testing.T) {
+	const resourceType = "genesyscloud_telephony_providers_edges_site"
+
+	testCases := map[string]struct {
+		overrides Overrides
+		valid     bool
+	}{
+		"by id": {
+			overrides: Overrides{resourceType: {{SourceId: uuid.NewString(), TargetId: uuid.NewString()}}},
+			valid:     true,
+		},
+		"by name": {
+			overrides: Overrides{resourceType: {{SourceName: "Headquarters", TargetId: uuid.NewString()}}},
+			valid:     true,
+		},
+		"id and name": {
+			overrides: Overrides{resourceType: {{SourceId: uuid.NewString(), SourceName: "Headquarters", TargetId: uuid.NewString()}}},
+		},
+		"no source": {
+			overrides: Overrides{resourceType: {{TargetId: uuid.NewString()}}},
+		},
+		"invalid target id": {
+			overrides: Overrides{resourceType: {{SourceName: "Headquarters", TargetId: "<site ID in target org>"}}},
+		},
+		"duplicate source": {
+			overrides: Overrides{resourceType: {
+				{SourceName: "Headquarters", TargetId: uuid.NewString()},
+				{SourceName: "Headquarters", TargetId: uuid.NewString()},
+			}},
+		},
+	}
+
+	for name, tc := range testCases {
+		err := tc.overrides.validate()
+		if tc.valid && err != nil {
+			t.Errorf("%s: expected overrides to be valid, got error: %s", name, err.Error())
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected overrides to be invalid", name)
+		}
+	}
+}
+
+func TestUnitParseOverridesExample(t *testing.T) {
+	overrides, err := ParseOverrides("../../overrides_example.yml")
+	if err != nil {
+		t.Fatalf("expected the example overrides file to be valid: %s", err.Error())
+	}
+	if _, ok := overrides.LookupByName("genesyscloud_telephony_providers_edges_site", "Headquarters"); !ok {
+		t.Error("expected the example to override the Headquarters site")
+	}
+}
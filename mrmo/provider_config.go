@@ -0,0 +1,167 @@
+package mrmo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strings"
+)
+
+const (
+	providerConfigFile = "provider.tf.json"
+	providerName       = "genesyscloud"
+	providerSource     = "registry.terraform.io/mypurecloud/genesyscloud"
+	providerModulePath = "github.com/mypurecloud/terraform-provider-genesyscloud"
+)
+
+// exporterProviderVersion returns the version of the provider module the exporter was built from, which is the version
+// target configs must be applied with. A module replaced with a local path in go.mod has the version it is required
+// at. Returns an error when the build info has no version for the module, in which case providerVersion must be set in
+// the credentials file.
+func exporterProviderVersion() (string, error) {
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range buildInfo.Deps {
+			if dep.Path != providerModulePath {
+				continue
+			}
+			// a local path replacement is reported as "(devel)"
+			version := dep.Version
+			if dep.Replace != nil && dep.Replace.Version != "" && dep.Replace.Version != "(devel)" {
+				version = dep.Replace.Version
+			}
+			if version != "" && version != "(devel)" {
+				return strings.TrimPrefix(version, "v"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the version of %s is not known from the build info. Set providerVersion in the credentials file", providerModulePath)
+}
+
+// renderProviderConfig returns the terraform and provider blocks of a target org's config directory. The provider is
+// pinned to version and configured with the target's region. If the target has a provider alias, an aliased provider
+// configuration is added alongside the default one.
+func renderProviderConfig(target orgManager.OrgData, version string) map[string]any {
+	providerBlock := make(map[string]any)
+	if target.Region != "" {
+		providerBlock["aws_region"] = target.Region
+	}
+
+	var providers any = providerBlock
+	if target.ProviderAlias != "" {
+		aliased := map[string]any{"alias": target.ProviderAlias}
+		for k, v := range providerBlock {
+			aliased[k] = v
+		}
+		providers = []any{providerBlock, aliased}
+	}
+
+	return map[string]any{
+		"terraform": map[string]any{
+			"required_providers": map[string]any{
+				providerName: map[string]any{
+					"source":  providerSource,
+					"version": version,
+				},
+			},
+		},
+		"provider": map[string]any{providerName: providers},
+	}
+}
+
+// writeProviderConfig generates the provider config of the target org's config directory, pinning the provider version
+// the exporter was built with (or the version configured in the credentials file). Warnings are returned when the
+// pinned version differs from the exporter's, from the version previously pinned in the directory, or from the version
+// recorded in the directory's lock file.
+func (f *FileManager) writeProviderConfig(ctx context.Context, target orgManager.OrgData, configuredVersion string) (diags diag.Diagnostics) {
+	exporterVersion, err := exporterProviderVersion()
+	if err != nil && configuredVersion == "" {
+		return diag.FromErr(err)
+	}
+	version := exporterVersion
+	if configuredVersion != "" {
+		version = configuredVersion
+		if exporterVersion != "" && configuredVersion != exporterVersion {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Provider version %s differs from the exporter's version %s", configuredVersion, exporterVersion),
+				Detail:   "Configs exported with one version of the provider may not apply cleanly with another.",
+			})
+		}
+	}
+
+	providerFile := filepath.Join(f.targetConfigDir, providerConfigFile)
+	if previousVersion := readPinnedProviderVersion(providerFile); previousVersion != "" && previousVersion != version {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Changing the provider version of org '%s' from %s to %s", target.OrgId, previousVersion, version),
+			Detail:   fmt.Sprintf("'%s' pinned a different version than the one Mr Mo was built with.", providerFile),
+		})
+	}
+
+	lockFile := filepath.Join(f.targetConfigDir, ".terraform.lock.hcl")
+	if lockedVersion := readLockedProviderVersion(lockFile); lockedVersion != "" && lockedVersion != version {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Lock file of org '%s' selects provider version %s, but %s is required", target.OrgId, lockedVersion, version),
+			Detail:   fmt.Sprintf("Run init with -upgrade in '%s' to update '%s'.", f.targetConfigDir, lockFile),
+		})
+	}
+
+	data, err := json.MarshalIndent(renderProviderConfig(target, version), "", "  ")
+	if err != nil {
+		return append(diags, diag.Errorf("failed to marshal provider config. Error: %s", err.Error())...)
+	}
+	if existing, err := os.ReadFile(providerFile); err == nil && string(existing) == string(data) {
+		return diags
+	}
+
+	log.Printf("Writing provider config '%s'", providerFile)
+	if err = writeChecksummedFile(providerFile, data); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	return append(diags, f.push(ctx, providerFile)...)
+}
+
+// readPinnedProviderVersion returns the provider version required by the provider config file, or an empty string if
+// the file doesn't exist or doesn't pin a version
+func readPinnedProviderVersion(providerFile string) string {
+	data, err := os.ReadFile(providerFile)
+	if err != nil {
+		return ""
+	}
+
+	var providerConfig struct {
+		Terraform struct {
+			RequiredProviders map[string]struct {
+				Version string `json:"version"`
+			} `json:"required_providers"`
+		} `json:"terraform"`
+	}
+	if err = json.Unmarshal(data, &providerConfig); err != nil {
+		log.Printf("Failed to parse provider config '%s'. Error: %s", providerFile, err.Error())
+		return ""
+	}
+	return providerConfig.Terraform.RequiredProviders[providerName].Version
+}
+
+var lockedProviderVersionRegex = regexp.MustCompile(`(?s)provider "` + regexp.QuoteMeta(providerSource) + `" \{.*?version\s*=\s*"([^"]+)"`)
+
+// readLockedProviderVersion returns the provider version selected in the lock file, or an empty string if there is no
+// lock file or it doesn't contain the provider
+func readLockedProviderVersion(lockFile string) string {
+	data, err := os.ReadFile(lockFile)
+	if err != nil {
+		return ""
+	}
+	if match := lockedProviderVersionRegex.FindSubmatch(data); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package mrmo
+
+import (
+	"context"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnitWriteProviderConfig(t *testing.T) {
+	dir := t.TempDir()
+	fm := &FileManager{targetOrgId: "org-a", targetConfigDir: dir}
+	target := orgManager.OrgData{OrgId: "org-a", Region: "eu-west-1"}
+
+	lockFile := `provider "registry.terraform.io/mypurecloud/genesyscloud" {
+  version     = "1.62.0"
+  constraints = "1.62.0"
+  hashes = [
+    "h1:abc=",
+  ]
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, ".terraform.lock.hcl"), []byte(lockFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diags := fm.writeProviderConfig(context.Background(), target, "")
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if len(diags) != 1 {
+		t.Fatalf("expected a warning about the lock file, got %v", diags)
+	}
+
+	// the provider module is replaced with a local path in go.mod, at the version it is required at
+	exporterVersion, err := exporterProviderVersion()
+	if err != nil || exporterVersion != "1.64.0" {
+		t.Fatalf("expected the exporter's provider version to be the one required in go.mod, got '%s' (%v)", exporterVersion, err)
+	}
+	if version := readPinnedProviderVersion(filepath.Join(dir, providerConfigFile)); version != exporterVersion {
+		t.Errorf("expected provider version %s to be pinned, got '%s'", exporterVersion, version)
+	}
+
+	// a different configured version is pinned, with warnings about the exporter version and the previous pin
+	diags = fm.writeProviderConfig(context.Background(), target, "0.0.1")
+	if len(diags) != 3 {
+		t.Errorf("expected 3 warnings, got %v", diags)
+	}
+	if version := readPinnedProviderVersion(filepath.Join(dir, providerConfigFile)); version != "0.0.1" {
+		t.Errorf("expected configured provider version to be pinned, got '%s'", version)
+	}
+}
+
+func TestUnitRenderProviderConfigWithAlias(t *testing.T) {
+	config := renderProviderConfig(orgManager.OrgData{Region: "us-east-1", ProviderAlias: "org_a"}, "1.64.0")
+
+	providers, ok := config["provider"].(map[string]any)[providerName].([]any)
+	if !ok || len(providers) != 2 {
+		t.Fatalf("expected default and aliased provider configurations, got %v", config["provider"])
+	}
+	aliased := providers[1].(map[string]any)
+	if aliased["alias"] != "org_a" || aliased["aws_region"] != "us-east-1" {
+		t.Errorf("unexpected aliased provider configuration %v", aliased)
+	}
+}
@@ -0,0 +1,56 @@
+package mrmo
+
+import (
+	mockDynamo "github.com/charliecon/mr-mo-trial-run/mock-dynamo"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
+)
+
+// Status describes the orgs Mr Mo replicates to and how references into each of them are resolved
+type Status struct {
+	Source  orgManager.OrgData
+	Targets []TargetStatus
+}
+
+// TargetStatus describes a single target org
+type TargetStatus struct {
+	OrgId          string
+	Name           string
+	Region         string
+	MappedEntities int
+
+	// OverridesFile is the path of the target's overrides file, if it has one
+	OverridesFile string
+	// Overrides is the number of manual overrides, keyed by resource type
+	Overrides map[string]int
+}
+
+// GetStatus loads the credentials file (validating every overrides file it references) and reports the state of each
+// target org
+func GetStatus(credentialsFilePath string) (*Status, error) {
+	credData, err := orgManager.ParseCredentialData(credentialsFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	status := Status{Source: credData.Source}
+	for _, target := range credData.Targets {
+		mappedEntities, err := mockDynamo.CountTargetMappings(target.OrgId)
+		if err != nil {
+			return nil, err
+		}
+
+		targetStatus := TargetStatus{
+			OrgId:          target.OrgId,
+			Name:           target.Name,
+			Region:         target.Region,
+			MappedEntities: mappedEntities,
+			OverridesFile:  target.OverridesFile,
+			Overrides:      make(map[string]int),
+		}
+		for resourceType, overrides := range target.Overrides {
+			targetStatus.Overrides[resourceType] = len(overrides)
+		}
+		status.Targets = append(status.Targets, targetStatus)
+	}
+	return &status, nil
+}
@@ -1,109 +1,204 @@
 package mrmo
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	orgManager "github.com/charliecon/mr-mo-trial-run/mrmo/org_manager"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
 )
 
-// applyWithOpenTofu executes OpenTofu commands to apply infrastructure changes for a given resource.
+// applyWithExecutor runs the IaC executor (OpenTofu by default) to apply infrastructure changes for a given resource.
 //
 // Parameters:
-//   - dir: The directory path containing the OpenTofu configuration files
+//   - ctx: Context bounding every run of the executor
+//   - iac: The executor used to run init, apply and output
+//   - dir: The directory path containing the configuration files
 //   - sourceEntityId: The identifier of the source entity being processed
-//   - resourcePath: The specific resource path to target in the OpenTofu configuration (e.g., "genesyscloud_group.example")
-//   - isDelete: Boolean flag indicating if this is a delete operation
+//   - resourcePath: The specific resource path to target in the configuration (e.g., "genesyscloud_group.example")
 //
 // Returns:
-//   - string: The target entity ID extracted from OpenTofu outputs (empty string if isDelete is true)
+//   - string: The target entity ID extracted from the outputs
 //   - diag.Diagnostics: Collection of any diagnostic messages or errors encountered
 //
 // The function performs the following steps:
-//  1. Initializes OpenTofu in the specified directory
-//  2. Applies the configuration with appropriate targeting
-//  3. For non-delete operations, extracts and returns the target entity ID from outputs
-func applyWithOpenTofu(dir, sourceEntityId, resourcePath string, isDelete bool) (_ string, diags diag.Diagnostics) {
-	// Initialize OpenTofu
-	initCmd := exec.Command("tofu", "init")
-	initCmd.Dir = dir
-	initCmd.Stdout = os.Stdout
-	initCmd.Stderr = os.Stderr
-
-	if err := initCmd.Run(); err != nil {
-		diags = append(diags, diag.FromErr(err)...)
+//  1. Initializes the config directory
+//  2. Applies the configuration, targeting resourcePath
+//  3. Extracts and returns the target entity ID from outputs
+func applyWithExecutor(ctx context.Context, iac executor.Executor, dir, sourceEntityId, resourcePath string) (_ string, diags diag.Diagnostics) {
+	diags = append(diags, iac.Init(ctx, dir)...)
+	if diags.HasError() {
 		return "", diags
 	}
 
-	applyCommand := []string{"apply", "-auto-approve"}
-	if !isDelete {
-		applyCommand = append(applyCommand, "-target", resourcePath)
+	diags = append(diags, iac.Apply(ctx, dir, executor.ApplyOptions{Targets: []string{resourcePath}})...)
+	if diags.HasError() {
+		return "", diags
 	}
 
-	// Apply the configuration
-	applyCmd := exec.Command("tofu", applyCommand...)
-	applyCmd.Dir = dir
-	applyCmd.Stdout = os.Stdout
-	applyCmd.Stderr = os.Stderr
-
-	if err := applyCmd.Run(); err != nil {
+	targetEntityId, err := extractTargetEntityIdFromOutputs(ctx, iac, dir, sourceEntityId)
+	if err != nil {
 		diags = append(diags, diag.FromErr(err)...)
 		return "", diags
 	}
 
-	if isDelete {
-		return "", diags
+	return targetEntityId, diags
+}
+
+// timeoutDiagnostic reports that the changes to subject in the target org timed out. The state of the target org may
+// be partially updated, and is reconciled by the next run.
+func timeoutDiagnostic(subject string, target orgManager.OrgData) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("Timed out applying %s to org '%s' (%s)", subject, target.Name, target.OrgId),
+		Detail:   "The remaining target orgs were not processed. Changes made before the timeout are kept in state and reconciled by the next run.",
 	}
+}
+
+// destroyPlanFile is the path, relative to the config directory, of the plan created before a destroy
+const destroyPlanFile = ".mrmo/destroy.tfplan"
 
-	targetEntityId, err := extractTargetEntityIdFromOutputs(dir, sourceEntityId)
+// destroyWithExecutor destroys exactly one entity in the target org.
+//
+// Parameters:
+//   - ctx: Context bounding every run of the executor
+//   - iac: The executor used to run init, plan and apply
+//   - dir: The directory path containing the configuration and state
+//   - resourceType: The resource type of the entity
+//   - targetEntityId: The ID of the entity in the target org
+//
+// Returns:
+//   - diag.Diagnostics: Collection of any diagnostic messages or errors encountered
+//
+// The address of the entity is resolved from the state. A destroy plan targeting that address is created, each of its
+// changes is logged, and the plan is refused if it would change any other resource. Otherwise, the saved plan is
+// applied, so exactly the reviewed changes are made.
+func destroyWithExecutor(ctx context.Context, iac executor.Executor, dir, resourceType, targetEntityId string) (diags diag.Diagnostics) {
+	diags = append(diags, iac.Init(ctx, dir)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	address, err := findStateAddress(ctx, iac, dir, resourceType, targetEntityId)
 	if err != nil {
-		diags = append(diags, diag.FromErr(err)...)
-		return "", diags
+		return append(diags, diag.FromErr(err)...)
+	}
+	if address == "" {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("%s '%s' not found in state", resourceType, targetEntityId),
+			Detail:   fmt.Sprintf("Nothing was destroyed in '%s'. The entity may have already been deleted.", dir),
+		})
 	}
 
-	return targetEntityId, diags
+	if err = os.MkdirAll(filepath.Dir(filepath.Join(dir, destroyPlanFile)), os.ModePerm); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	defer func() {
+		if err := os.Remove(filepath.Join(dir, destroyPlanFile)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove plan file '%s'. Error: %s", destroyPlanFile, err.Error())
+		}
+	}()
+
+	plan, planDiags := iac.Plan(ctx, dir, executor.PlanOptions{
+		Targets:  []string{address},
+		Destroy:  true,
+		PlanFile: destroyPlanFile,
+	})
+	diags = append(diags, planDiags...)
+	if diags.HasError() {
+		return diags
+	}
+
+	diags = append(diags, verifyDestroyPlan(plan, address)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, iac.Apply(ctx, dir, executor.ApplyOptions{PlanFile: destroyPlanFile})...)
+}
+
+// verifyDestroyPlan logs each change in the plan and returns an error diagnostic for every change that affects a
+// resource other than address
+func verifyDestroyPlan(plan *executor.Plan, address string) (diags diag.Diagnostics) {
+	for _, change := range plan.ResourceChanges {
+		log.Printf("Plan: %s %v", change.Address, change.Actions)
+		if change.Address == address || change.IsNoOp() {
+			continue
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Refusing to destroy %s", address),
+			Detail:   fmt.Sprintf("The destroy plan would also change %s %v.", change.Address, change.Actions),
+		})
+	}
+	return
+}
+
+// findStateAddress returns the address of the resource of the given type whose ID is targetEntityId, or an empty
+// string if there is no such resource in the state
+func findStateAddress(ctx context.Context, iac executor.Executor, dir, resourceType, targetEntityId string) (string, error) {
+	resources, err := iac.State(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, resource := range resources {
+		if resource.Type == resourceType && resource.Values["id"] == targetEntityId {
+			return resource.Address, nil
+		}
+	}
+	return "", nil
+}
+
+// moveStateAddress moves the resource at address from to address to in the state of dir. Nothing is done if there is
+// no resource at from, e.g. because the entity was never applied.
+func moveStateAddress(ctx context.Context, iac executor.Executor, dir, from, to string) diag.Diagnostics {
+	resources, err := iac.State(ctx, dir)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	for _, resource := range resources {
+		if resource.Address == from {
+			log.Printf("Moving %s to %s in the state of '%s'", from, to, dir)
+			return iac.MoveState(ctx, dir, from, to)
+		}
+	}
+	return nil
 }
 
-// extractTargetEntityIdFromOutputs retrieves and parses the OpenTofu outputs to find the target entity ID
+// extractTargetEntityIdFromOutputs retrieves the outputs of the config directory to find the target entity ID
 // corresponding to the given source entity.
 //
 // Parameters:
-//   - dir: The directory path containing the OpenTofu configuration and state
+//   - ctx: Context bounding the run of the executor
+//   - iac: The executor used to read the outputs
+//   - dir: The directory path containing the configuration and state
 //   - sourceEntityId: The identifier of the source entity. This is used as the output label in the target tf configuration, with
 //     the value being the ID of the target entity.
 //
 // Returns:
 //   - string: The target entity ID found in the outputs
-//   - error: Error if the output command fails, JSON parsing fails, or the target entity ID cannot be found
+//   - error: Error if the outputs cannot be read, or the target entity ID cannot be found
 //
-// The function executes 'tofu output -json' and parses the JSON response to find an output block
-// that matches the source entity ID. If no matching output is found, returns an error with details about the missing output.
-func extractTargetEntityIdFromOutputs(dir, sourceEntityId string) (string, error) {
-	var outputBuffer bytes.Buffer
-
-	// Get the output values
-	outputCmd := exec.Command("tofu", "output", "-json")
-	outputCmd.Dir = dir
-	outputCmd.Stdout = &outputBuffer
-	outputCmd.Stderr = os.Stderr
-
-	if err := outputCmd.Run(); err != nil {
-		return "", err
-	}
-
-	// Parse the JSON output
-	var outputs map[string]struct {
-		Value string `json:"value"`
-	}
-	if err := json.Unmarshal(outputBuffer.Bytes(), &outputs); err != nil {
+// The function looks for an output block that matches the source entity ID. If no matching output is found, returns
+// an error with details about the missing output.
+func extractTargetEntityIdFromOutputs(ctx context.Context, iac executor.Executor, dir, sourceEntityId string) (string, error) {
+	outputs, err := iac.Output(ctx, dir)
+	if err != nil {
 		return "", err
 	}
 
 	// Assuming output block is named after the source entity ID
 	if output, exists := outputs[buildOutputKey(sourceEntityId)]; exists {
-		return output.Value, nil
+		if value, ok := output.(string); ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("expected output for source entity '%s' to be a string, got %T", sourceEntityId, output)
 	}
 
 	return "", fmt.Errorf("could not find target entity ID in outputs for source entity '%s'. Dir: '%s'", sourceEntityId, dir)
@@ -0,0 +1,63 @@
+package mrmo
+
+import (
+	"context"
+	"github.com/charliecon/mr-mo-trial-run/mrmo/executor"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestUnitApplyWithExecutor(t *testing.T) {
+	const resourcePath = "genesyscloud_routing_wrapupcode.example"
+	sourceEntityId := uuid.NewString()
+	targetEntityId := uuid.NewString()
+
+	fake := &fakeExecutor{
+		outputs: map[string]any{buildOutputKey(sourceEntityId): targetEntityId},
+	}
+
+	id, diags := applyWithExecutor(context.Background(), fake, "dir", sourceEntityId, resourcePath)
+	if diags.HasError() {
+		t.Fatalf("Unexpected error: %v", diags)
+	}
+	if id != targetEntityId {
+		t.Errorf("Expected target entity ID '%s', got '%s'", targetEntityId, id)
+	}
+
+	expectedCommands := []string{"init", "apply", "output"}
+	if len(fake.commands) != len(expectedCommands) {
+		t.Fatalf("Expected commands %v, got %v", expectedCommands, fake.commands)
+	}
+	for i := range expectedCommands {
+		if fake.commands[i] != expectedCommands[i] {
+			t.Errorf("Expected commands %v, got %v", expectedCommands, fake.commands)
+			break
+		}
+	}
+
+	if targets := fake.applies[0].Targets; len(targets) != 1 || targets[0] != resourcePath {
+		t.Errorf("Expected apply to target '%s', got %v", resourcePath, targets)
+	}
+}
+
+func TestUnitVerifyDestroyPlan(t *testing.T) {
+	const address = "genesyscloud_routing_wrapupcode.example"
+
+	plan := &executor.Plan{
+		ResourceChanges: []executor.ResourceChange{
+			{Address: address, Actions: []string{"delete"}},
+			{Address: "genesyscloud_routing_queue.unchanged", Actions: []string{"no-op"}},
+		},
+	}
+	if diags := verifyDestroyPlan(plan, address); diags.HasError() {
+		t.Errorf("Expected plan to be accepted, got %v", diags)
+	}
+
+	plan.ResourceChanges = append(plan.ResourceChanges, executor.ResourceChange{
+		Address: "genesyscloud_routing_queue.drifted",
+		Actions: []string{"update"},
+	})
+	if diags := verifyDestroyPlan(plan, address); !diags.HasError() {
+		t.Error("Expected plan changing another resource to be refused")
+	}
+}